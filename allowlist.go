@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// allowlistHost is a destination that must be reachable through the customer's firewall.
+// Host may contain the {domain} and {region} placeholders, which are derived from the gateway URL.
+type allowlistHost struct {
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	Purpose string `json:"purpose"`
+}
+
+// allowlistConfig lists the Infor hosts to check besides the gateway and the STS,
+// for every region and per region
+type allowlistConfig struct {
+	Hosts   []allowlistHost            `json:"hosts"`
+	Regions map[string][]allowlistHost `json:"regions"`
+}
+
+// defaultAllowlistConfig is used when no --hosts file is given
+var defaultAllowlistConfig = allowlistConfig{
+	Hosts: []allowlistHost{
+		{Host: "mingle-portal.{domain}", Port: 443, Purpose: "Infor OS portal, ION Desk and Data Lake UI"},
+		{Host: "mingle-sso.{domain}", Port: 443, Purpose: "Infor single sign-on"},
+	},
+}
+
+// allowlistEntry is the result of checking a single destination
+type allowlistEntry struct {
+	Host    string   `json:"host"`
+	Port    string   `json:"port"`
+	Purpose string   `json:"purpose"`
+	IPs     []string `json:"resolved_ips"`
+	TCP     bool     `json:"tcp"`
+	TLS     bool     `json:"tls"`
+	Status  string   `json:"status"`
+	Detail  string   `json:"detail,omitempty"`
+}
+
+// runAllowlist derives the destinations required by the .ionapi file, checks TCP and TLS
+// reachability to each and writes an allowlist document for the firewall team
+func runAllowlist(args []string) error {
+	cli := parseArgs(args, "--hosts", "--output")
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: allowlist <ionapi-file-path> [--hosts <file>] [--output <file>]")
+	}
+	applyGlobalFlags(cli)
+
+	ionAPI, err := loadIonAPI(cli.positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to load ionapi file: %v", err)
+	}
+
+	config := defaultAllowlistConfig
+	if hostsFile := cli.value("--hosts", ""); hostsFile != "" {
+		data, err := ioutil.ReadFile(hostsFile)
+		if err != nil {
+			return err
+		}
+		config = allowlistConfig{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("failed to parse hosts file %s: %v", hostsFile, err)
+		}
+	}
+
+	hosts, err := allowlistHosts(ionAPI, config)
+	if err != nil {
+		return err
+	}
+
+	var entries []allowlistEntry
+	for _, host := range hosts {
+		entry := checkAllowlistHost(host)
+		if entry.Status == "open" {
+			logger.Printf("✅ %s:%s is open (%s)", entry.Host, entry.Port, strings.Join(entry.IPs, ", "))
+		} else {
+			logger.Printf("❌ %s:%s %s: %s", entry.Host, entry.Port, entry.Status, entry.Detail)
+		}
+		entries = append(entries, entry)
+	}
+	report.Allowlist = entries
+
+	printAllowlist(entries)
+	output := cli.value("--output", "allowlist.md")
+	if err := writeAllowlist(output, ionAPI, entries); err != nil {
+		return err
+	}
+	logger.Printf("📝 Allowlist written to %s", output)
+	finishReport()
+	return nil
+}
+
+// allowlistHosts returns the gateway, the STS and the configured hosts for the gateway's region,
+// without duplicates
+func allowlistHosts(api *IonAPI, config allowlistConfig) ([]allowlistHost, error) {
+	gateway, err := hostFromURL(api.IonBaseURL, "ION API Gateway")
+	if err != nil {
+		return nil, err
+	}
+	sts, err := hostFromURL(api.GetTokenURL(), "Authorization server (STS)")
+	if err != nil {
+		return nil, err
+	}
+
+	// mingle-ionapi.eu1.inforcloudsuite.com is in domain eu1.inforcloudsuite.com and region eu1
+	domain := gateway.Host
+	if i := strings.Index(domain, "."); i >= 0 {
+		domain = domain[i+1:]
+	}
+	region := "default"
+	if strings.HasSuffix(domain, ".inforcloudsuite.com") {
+		region = strings.TrimSuffix(domain, ".inforcloudsuite.com")
+	}
+	logger.Printf("🌍 Gateway domain %s, region %s", domain, region)
+
+	hosts := []allowlistHost{gateway, sts}
+	seen := map[string]int{}
+	var unique []allowlistHost
+	for _, host := range append(append(hosts, config.Hosts...), config.Regions[region]...) {
+		host.Host = strings.NewReplacer("{domain}", domain, "{region}", region).Replace(host.Host)
+		if host.Port == 0 {
+			host.Port = 443
+		}
+		key := net.JoinHostPort(strings.ToLower(host.Host), strconv.Itoa(host.Port))
+		if i, ok := seen[key]; ok {
+			unique[i].Purpose += ", " + host.Purpose
+			continue
+		}
+		seen[key] = len(unique)
+		unique = append(unique, host)
+	}
+	return unique, nil
+}
+
+// hostFromURL returns the host and port of a URL, defaulting the port from the scheme
+func hostFromURL(rawURL, purpose string) (allowlistHost, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Hostname() == "" {
+		return allowlistHost{}, fmt.Errorf("failed to parse URL %q", rawURL)
+	}
+	port := 443
+	if parsedURL.Scheme == "http" {
+		port = 80
+	}
+	if parsedURL.Port() != "" {
+		port, err = strconv.Atoi(parsedURL.Port())
+		if err != nil {
+			return allowlistHost{}, fmt.Errorf("invalid port in URL %q", rawURL)
+		}
+	}
+	return allowlistHost{Host: parsedURL.Hostname(), Port: port, Purpose: purpose}, nil
+}
+
+// checkAllowlistHost resolves the host and checks that TCP and TLS connections succeed
+func checkAllowlistHost(host allowlistHost) allowlistEntry {
+	entry := allowlistEntry{Host: host.Host, Port: strconv.Itoa(host.Port), Purpose: host.Purpose}
+	address := net.JoinHostPort(host.Host, entry.Port)
+
+	if ip, ok := resolveOverrides[net.JoinHostPort(strings.ToLower(host.Host), entry.Port)]; ok {
+		entry.IPs = []string{ip + " (--resolve)"}
+	} else {
+		ips, err := net.LookupHost(host.Host)
+		if err != nil {
+			entry.Status = "DNS failed"
+			entry.Detail = err.Error()
+			return entry
+		}
+		entry.IPs = ips
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", address)
+	if err != nil {
+		entry.Status = "TCP blocked"
+		entry.Detail = err.Error()
+		return entry
+	}
+	conn.Close()
+	entry.TCP = true
+
+	tlsConn, err := dialTLS(ctx, "tcp", address, &tls.Config{})
+	if err != nil {
+		entry.Status = "TLS failed"
+		entry.Detail = err.Error()
+		return entry
+	}
+	tlsConn.Close()
+	entry.TLS = true
+	entry.Status = "open"
+	return entry
+}
+
+// printAllowlist prints the checked destinations as a table
+func printAllowlist(entries []allowlistEntry) {
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOST\tPORT\tRESOLVED IPS\tSTATUS\tPURPOSE")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Host, e.Port, strings.Join(e.IPs, ", "), e.Status, e.Purpose)
+	}
+	w.Flush()
+}
+
+// writeAllowlist writes the allowlist document, as CSV if the path ends in .csv and as Markdown otherwise
+func writeAllowlist(path string, api *IonAPI, entries []allowlistEntry) error {
+	var b strings.Builder
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		w := csv.NewWriter(&b)
+		w.Write([]string{"host", "port", "resolved_ips", "status", "purpose"})
+		for _, e := range entries {
+			w.Write([]string{e.Host, e.Port, strings.Join(e.IPs, " "), e.Status, e.Purpose})
+		}
+		w.Flush()
+		return ioutil.WriteFile(path, []byte(b.String()), 0644)
+	}
+
+	fmt.Fprintf(&b, "# Firewall allowlist for tenant %s\n\n", api.TenantID)
+	fmt.Fprintf(&b, "Generated on %s. All connections are outbound HTTPS (TCP).\n\n", time.Now().Format("2006-01-02 15:04 MST"))
+	b.WriteString("| Host | Port | Resolved IPs | Status | Purpose |\n")
+	b.WriteString("|------|------|--------------|--------|---------|\n")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", e.Host, e.Port, strings.Join(e.IPs, ", "), e.Status, e.Purpose)
+	}
+	b.WriteString("\nResolved IPs can change, allowlisting by hostname is recommended where the firewall supports it.\n")
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
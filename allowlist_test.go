@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestAllowlistHosts(t *testing.T) {
+	api := &IonAPI{
+		IonBaseURL:   "https://mingle-ionapi.eu1.inforcloudsuite.com",
+		TokenBaseURL: "https://mingle-sso.eu1.inforcloudsuite.com:443/ACME_TST/as/",
+		TokenPath:    "token.oauth2",
+	}
+	config := allowlistConfig{
+		Hosts: []allowlistHost{
+			{Host: "mingle-portal.{domain}", Purpose: "Portal"},
+			{Host: "MINGLE-SSO.{domain}", Port: 443, Purpose: "SSO"},
+		},
+		Regions: map[string][]allowlistHost{
+			"eu1": {{Host: "files.{region}.example.com", Port: 8443, Purpose: "Files"}},
+			"us1": {{Host: "files.us1.example.com", Port: 8443, Purpose: "Files"}},
+		},
+	}
+	hosts, err := allowlistHosts(api, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []allowlistHost{
+		{Host: "mingle-ionapi.eu1.inforcloudsuite.com", Port: 443, Purpose: "ION API Gateway"},
+		{Host: "mingle-sso.eu1.inforcloudsuite.com", Port: 443, Purpose: "Authorization server (STS), SSO"},
+		{Host: "mingle-portal.eu1.inforcloudsuite.com", Port: 443, Purpose: "Portal"},
+		{Host: "files.eu1.example.com", Port: 8443, Purpose: "Files"},
+	}
+	if !reflect.DeepEqual(hosts, want) {
+		t.Errorf("allowlistHosts() = %+v, want %+v", hosts, want)
+	}
+
+	api.IonBaseURL = "http://gateway.example.com:8080"
+	hosts, err = allowlistHosts(api, allowlistConfig{Hosts: []allowlistHost{{Host: "portal.{domain}", Purpose: "Portal"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hosts[0].Port != 8080 || hosts[2].Host != "portal.example.com" {
+		t.Errorf("allowlistHosts() outside Infor Cloud = %+v", hosts)
+	}
+
+	api.IonBaseURL = "not a url"
+	if _, err := allowlistHosts(api, config); err == nil {
+		t.Error("allowlistHosts() accepted a gateway URL without a host")
+	}
+}
+
+func TestHostFromURL(t *testing.T) {
+	tests := []struct {
+		rawURL  string
+		host    string
+		port    int
+		wantErr bool
+	}{
+		{rawURL: "https://mingle-ionapi.inforcloudsuite.com/ACME_TST", host: "mingle-ionapi.inforcloudsuite.com", port: 443},
+		{rawURL: "http://gateway.example.com", host: "gateway.example.com", port: 80},
+		{rawURL: "https://gateway.example.com:8443/", host: "gateway.example.com", port: 8443},
+		{rawURL: "https://[2001:db8::1]:9443", host: "2001:db8::1", port: 9443},
+		{rawURL: "gateway.example.com", wantErr: true},
+		{rawURL: "https://gateway.example.com:https", wantErr: true},
+	}
+	for _, tt := range tests {
+		host, err := hostFromURL(tt.rawURL, "test")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("hostFromURL(%q) = %+v, want an error", tt.rawURL, host)
+			}
+			continue
+		}
+		if err != nil || host.Host != tt.host || host.Port != tt.port {
+			t.Errorf("hostFromURL(%q) = %+v, %v, want %s port %d", tt.rawURL, host, err, tt.host, tt.port)
+		}
+	}
+}
+
+func TestRunAllowlist(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	trustServer(t, server)
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// A port nobody listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	// The certificate of the test server is valid for example.com, which is resolved to it
+	defer func(saved map[string]string) { resolveOverrides = saved }(resolveOverrides)
+	resolveOverrides = map[string]string{net.JoinHostPort("example.com", port): "127.0.0.1"}
+	defer func(saved []allowlistEntry) { report.Allowlist = saved }(report.Allowlist)
+
+	dir := t.TempDir()
+	gatewayURL := "https://example.com:" + port
+	ionAPIFile := filepath.Join(dir, "test.ionapi")
+	hostsFile := filepath.Join(dir, "hosts.json")
+	output := filepath.Join(dir, "allowlist.csv")
+	writeJSON := func(path string, value interface{}) {
+		data, _ := json.Marshal(value)
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeJSON(ionAPIFile, IonAPI{
+		ClientID:     "ACME_TST~client",
+		ClientSecret: "secret",
+		TokenBaseURL: gatewayURL + "/",
+		TokenPath:    "as/token.oauth2",
+		Username:     "ACME_TST#saak",
+		Password:     "sask",
+		IonBaseURL:   gatewayURL,
+		TenantID:     "ACME_TST",
+	})
+	writeJSON(hostsFile, allowlistConfig{Hosts: []allowlistHost{
+		{Host: "127.0.0.1", Port: closed, Purpose: "Closed port"},
+		{Host: "allowlist.invalid", Purpose: "Unknown host"},
+	}})
+
+	if err := runAllowlist([]string{ionAPIFile, "--hosts", hostsFile, "--output", output}); err != nil {
+		t.Fatalf("runAllowlist() failed: %v", err)
+	}
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"host", "port", "resolved_ips", "status", "purpose"},
+		{"example.com", port, "127.0.0.1 (--resolve)", "open", "ION API Gateway, Authorization server (STS)"},
+		{"127.0.0.1", strconv.Itoa(closed), "127.0.0.1", "TCP blocked", "Closed port"},
+		{"allowlist.invalid", "443", "", "DNS failed", "Unknown host"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("allowlist rows = %q, want %q", rows, want)
+	}
+	if len(report.Allowlist) != 3 || !report.Allowlist[0].TLS {
+		t.Errorf("report allowlist = %+v", report.Allowlist)
+	}
+}
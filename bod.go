@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// bodVerbs are the OAGIS verbs a BOD name starts with, such as Sync in SyncContactMaster
+var bodVerbs = []string{"Acknowledge", "Cancel", "Change", "Confirm", "Get", "List", "Load", "Notify", "Post", "Process", "Respond", "Show", "Sync", "Update"}
+
+// bodVerbNoun splits a BOD name such as SyncContactMaster into its verb and noun
+func bodVerbNoun(name string) (string, string, bool) {
+	for _, verb := range bodVerbs {
+		if strings.HasPrefix(name, verb) && len(name) > len(verb) {
+			return verb, name[len(verb):], true
+		}
+	}
+	return "", "", false
+}
+
+// bodRootName returns the local name of the root element of a BOD, such as SyncContactMaster
+func bodRootName(document string) (string, error) {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("failed to read the BOD root element: %v", err)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// bodVariable matches the ${NAME} placeholders of a BOD template
+var bodVariable = regexp.MustCompile(`\$\{([A-Za-z0-9_]+)\}`)
+
+// expandBODTemplate replaces the ${NAME} placeholders of a template with the XML-escaped values,
+// so they are safe in text and attribute values. Unknown placeholders are an error.
+func expandBODTemplate(template string, vars map[string]string) (string, error) {
+	missing := map[string]bool{}
+	expanded := bodVariable.ReplaceAllStringFunc(template, func(match string) string {
+		name := bodVariable.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing[name] = true
+			return ""
+		}
+		var escaped strings.Builder
+		xml.EscapeText(&escaped, []byte(value))
+		return escaped.String()
+	})
+	if len(missing) > 0 {
+		var names []string
+		for name := range missing {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("no value for template variable(s) %s, set them with --var NAME=VALUE", strings.Join(names, ", "))
+	}
+	return expanded, nil
+}
+
+// newUUID returns a random version 4 UUID, used for message and BOD IDs
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate a UUID: %v", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
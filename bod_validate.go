@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bodCommands maps the subcommands of the bod command to the functions running them
+var bodCommands = map[string]func(args []string) error{
+	"validate": runBODValidate,
+}
+
+// Severity of a BOD validation issue
+const (
+	bodError   = "error"
+	bodWarning = "warning"
+)
+
+// bodIssue is a problem found in a BOD, with the position of the element it concerns
+type bodIssue struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// bodValidation is the outcome of validating a BOD, added to the report
+type bodValidation struct {
+	File     string     `json:"file"`
+	Document string     `json:"document,omitempty"`
+	Schemas  []string   `json:"schemas,omitempty"`
+	Valid    bool       `json:"valid"`
+	Issues   []bodIssue `json:"issues,omitempty"`
+}
+
+// bodExpectations are the envelope values a BOD must have, empty values are not checked
+type bodExpectations struct {
+	TenantID  string
+	LogicalID string
+}
+
+// bodElement is an element of a parsed BOD with its position in the document
+type bodElement struct {
+	Name     string
+	Line     int
+	Column   int
+	Text     string
+	Children []*bodElement
+}
+
+// child returns the first child element with the given local name
+func (e *bodElement) child(name string) *bodElement {
+	for _, c := range e.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// runBOD runs a bod subcommand
+func runBOD(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: bod <validate> [arguments], see the usage of Infor-test")
+	}
+	command, ok := bodCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown bod subcommand %q", args[0])
+	}
+	return command(args[1:])
+}
+
+// runBODValidate checks BOD documents offline: bod validate <file.xml> ... [--xsd <schema.xsd>] [--tenant <id>] [--logical-id <lid>]
+func runBODValidate(args []string) error {
+	cli := parseArgs(args, "--xsd", "--tenant", "--logical-id")
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: bod validate <file.xml> ... [--xsd <schema.xsd>] [--tenant <tenant-id>] [--logical-id <lid>]")
+	}
+	applyGlobalFlags(cli)
+	expect := bodExpectations{TenantID: cli.value("--tenant", ""), LogicalID: cli.value("--logical-id", "")}
+
+	invalid := 0
+	for _, file := range cli.positional {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		validation := validateBOD(string(content), expect)
+		validation.File = file
+		for _, schema := range cli.values("--xsd") {
+			validation.Schemas = append(validation.Schemas, schema)
+			issues, err := validateBODSchema(file, schema)
+			if err != nil {
+				return err
+			}
+			validation.Issues = append(validation.Issues, issues...)
+		}
+		validation.Valid = !hasBODErrors(validation.Issues)
+		report.BODValidation = append(report.BODValidation, validation)
+
+		printBODIssues(file, validation.Issues)
+		if validation.Valid {
+			logger.Printf("✅ %s is a valid %s BOD (%d warning(s))", file, validation.Document, len(validation.Issues))
+		} else {
+			logger.Printf("❌ %s is not valid", file)
+			invalid++
+		}
+	}
+	finishReport()
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d BOD(s) are not valid", invalid, len(cli.positional))
+	}
+	return nil
+}
+
+// validateBOD checks that a BOD is well-formed and has a consistent envelope: a Verb+Noun root
+// element, an ApplicationArea with sender and creation time, and a DataArea with the verb and nouns.
+func validateBOD(document string, expect bodExpectations) *bodValidation {
+	validation := &bodValidation{}
+	issue := func(e *bodElement, severity, format string, args ...interface{}) {
+		validation.Issues = append(validation.Issues, bodIssue{Line: e.Line, Column: e.Column, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	root, err := parseBOD(document)
+	if err != nil {
+		validation.Issues = append(validation.Issues, *err)
+		return validation
+	}
+	// Templates can be validated before they are expanded, values of placeholders are not checked
+	for _, match := range bodVariable.FindAllStringIndex(document, -1) {
+		line := strings.Count(document[:match[0]], "\n") + 1
+		column := match[0] - strings.LastIndex(document[:match[0]], "\n")
+		issue(&bodElement{Line: line, Column: column}, bodWarning, "template placeholder %s is not replaced", document[match[0]:match[1]])
+	}
+	placeholder := bodVariable.MatchString
+
+	verb, noun, ok := bodVerbNoun(root.Name)
+	if !ok {
+		issue(root, bodError, "root element %s is not a BOD name such as SyncItemMaster", root.Name)
+	} else {
+		validation.Document = verb + "." + noun
+	}
+
+	applicationArea, dataArea := root.child("ApplicationArea"), root.child("DataArea")
+	if applicationArea == nil {
+		issue(root, bodError, "%s has no ApplicationArea", root.Name)
+	} else if root.Children[0] != applicationArea {
+		issue(applicationArea, bodError, "ApplicationArea must be the first element of %s", root.Name)
+	}
+	if dataArea == nil {
+		issue(root, bodError, "%s has no DataArea", root.Name)
+	}
+
+	if applicationArea != nil {
+		sender := applicationArea.child("Sender")
+		var logicalID *bodElement
+		if sender != nil {
+			logicalID = sender.child("LogicalID")
+		}
+		if sender == nil {
+			issue(applicationArea, bodError, "ApplicationArea has no Sender")
+		} else if logicalID == nil || logicalID.Text == "" {
+			issue(sender, bodError, "Sender has no LogicalID")
+		} else if placeholder(logicalID.Text) {
+			// Checked once the template is expanded
+		} else if expect.LogicalID != "" && logicalID.Text != expect.LogicalID {
+			issue(logicalID, bodError, "Sender LogicalID is %s, expected %s", logicalID.Text, expect.LogicalID)
+		} else if !strings.HasPrefix(logicalID.Text, "lid://") {
+			issue(logicalID, bodWarning, "Sender LogicalID %s does not start with lid://", logicalID.Text)
+		}
+
+		if created := applicationArea.child("CreationDateTime"); created == nil {
+			issue(applicationArea, bodError, "ApplicationArea has no CreationDateTime")
+		} else if !validDateTime(created.Text) && !placeholder(created.Text) {
+			issue(created, bodError, "CreationDateTime %q is not an xs:dateTime", created.Text)
+		}
+		if applicationArea.child("BODID") == nil {
+			issue(applicationArea, bodWarning, "ApplicationArea has no BODID")
+		}
+	}
+
+	if dataArea != nil && len(dataArea.Children) == 0 {
+		issue(dataArea, bodError, "DataArea is empty")
+	} else if dataArea != nil && ok {
+		verbElement := dataArea.Children[0]
+		if verbElement.Name != verb {
+			issue(verbElement, bodError, "DataArea starts with %s, expected the verb %s of %s", verbElement.Name, verb, root.Name)
+		} else if tenant := verbElement.child("TenantID"); tenant == nil || tenant.Text == "" {
+			issue(verbElement, bodWarning, "%s has no TenantID", verb)
+		} else if expect.TenantID != "" && tenant.Text != expect.TenantID && !placeholder(tenant.Text) {
+			issue(tenant, bodError, "TenantID is %s, expected %s", tenant.Text, expect.TenantID)
+		}
+
+		nouns := 0
+		for _, element := range dataArea.Children[1:] {
+			if element.Name != noun {
+				issue(element, bodError, "DataArea contains %s, expected the noun %s of %s", element.Name, noun, root.Name)
+				continue
+			}
+			nouns++
+		}
+		if nouns == 0 {
+			issue(dataArea, bodError, "DataArea has no %s", noun)
+		}
+	}
+	validation.Valid = !hasBODErrors(validation.Issues)
+	return validation
+}
+
+// bodDateTimeLayouts are the layouts of xs:dateTime, with a zone or without one
+var bodDateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"}
+
+// validDateTime reports whether the text is an xs:dateTime
+func validDateTime(text string) bool {
+	for _, layout := range bodDateTimeLayouts {
+		if _, err := time.Parse(layout, text); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseBOD reads a BOD into a tree of elements with their positions. Errors in the XML are
+// returned as an issue at the position where reading stopped.
+func parseBOD(document string) (*bodElement, *bodIssue) {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	var root *bodElement
+	var stack []*bodElement
+	for {
+		line, column := decoder.InputPos()
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF && len(stack) == 0 && root != nil {
+				return root, nil
+			}
+			line, column = decoder.InputPos()
+			message := err.Error()
+			var syntaxErr *xml.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				message = syntaxErr.Msg
+			} else if root == nil {
+				message = "document has no root element"
+			}
+			return nil, &bodIssue{Line: line, Column: column, Severity: bodError, Message: "XML is not well-formed: " + message}
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			element := &bodElement{Name: token.Name.Local, Line: line, Column: column}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, element)
+			} else if root != nil {
+				return nil, &bodIssue{Line: line, Column: column, Severity: bodError, Message: "XML is not well-formed: more than one root element"}
+			} else {
+				root = element
+			}
+			stack = append(stack, element)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].Text += strings.TrimSpace(string(token))
+			}
+		}
+	}
+}
+
+// xmllintIssue matches a schema validity error of xmllint, such as "file.xml:12: element ID: Schemas validity error : ..."
+var xmllintIssue = regexp.MustCompile(`^.*?:(\d+): (.*)$`)
+
+// validateBODSchema validates a BOD against an XSD. The Go standard library has no XML Schema
+// validator, so xmllint of libxml2 is used; it resolves the includes of the OAGIS schemas.
+func validateBODSchema(file, schema string) ([]bodIssue, error) {
+	xmllint, err := exec.LookPath("xmllint")
+	if err != nil {
+		return nil, fmt.Errorf("validating against %s requires xmllint (libxml2) on the PATH", schema)
+	}
+	output, err := exec.Command(xmllint, "--noout", "--schema", schema, file).CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run xmllint: %v", err)
+	}
+	debugPrint("xmllint output: %s", output)
+
+	var issues []bodIssue
+	for _, line := range strings.Split(string(output), "\n") {
+		match := xmllintIssue.FindStringSubmatch(line)
+		if match == nil || strings.HasSuffix(line, " validates") {
+			continue
+		}
+		number, _ := strconv.Atoi(match[1])
+		issues = append(issues, bodIssue{Line: number, Severity: bodError, Message: schema + ": " + match[2]})
+	}
+	if err != nil && len(issues) == 0 {
+		// xmllint failed without a positioned error, such as for a schema it cannot compile
+		issues = append(issues, bodIssue{Severity: bodError, Message: fmt.Sprintf("%s: %s", schema, strings.Join(strings.Fields(string(output)), " "))})
+	}
+	return issues, nil
+}
+
+// hasBODErrors reports whether any of the issues is an error
+func hasBODErrors(issues []bodIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == bodError {
+			return true
+		}
+	}
+	return false
+}
+
+// printBODIssues prints the issues as file:line:column: severity: message
+func printBODIssues(file string, issues []bodIssue) {
+	for _, issue := range issues {
+		position := file
+		if issue.Line > 0 {
+			position += fmt.Sprintf(":%d", issue.Line)
+		}
+		if issue.Column > 0 {
+			position += fmt.Sprintf(":%d", issue.Column)
+		}
+		fmt.Printf("%s: %s: %s\n", position, issue.Severity, issue.Message)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testBOD is a valid Sync.ItemMaster BOD, the tests replace parts of it
+const testBOD = `<?xml version="1.0" encoding="UTF-8"?>
+<SyncItemMaster releaseID="9.2" versionID="2.14.0">
+  <ApplicationArea>
+    <Sender><LogicalID>lid://infor.ims.test</LogicalID></Sender>
+    <CreationDateTime>2026-10-18T10:00:00Z</CreationDateTime>
+    <BODID>f8b5d3a6-4c7e-4a59-9b1f-0c2e6d7a8b90</BODID>
+  </ApplicationArea>
+  <DataArea>
+    <Sync><TenantID>ACME_TST</TenantID></Sync>
+    <ItemMaster><ItemMasterHeader><ItemID><ID>A100</ID></ItemID></ItemMasterHeader></ItemMaster>
+  </DataArea>
+</SyncItemMaster>`
+
+func TestParseBOD(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		root     string
+		line     int
+		message  string
+	}{
+		{name: "valid", document: testBOD, root: "SyncItemMaster"},
+		{name: "unclosed element", document: "<SyncItemMaster>\n  <ApplicationArea>\n</SyncItemMaster>", line: 3, message: "not well-formed"},
+		{name: "two roots", document: "<A/>\n<B/>", line: 2, message: "more than one root element"},
+		{name: "empty", document: "", message: "no root element"},
+		{name: "unterminated", document: "<SyncItemMaster>\n<DataArea>", message: "not well-formed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, issue := parseBOD(tt.document)
+			if tt.message == "" {
+				if issue != nil {
+					t.Fatalf("parseBOD() failed: %s", issue.Message)
+				}
+				if root.Name != tt.root {
+					t.Errorf("root = %s, want %s", root.Name, tt.root)
+				}
+				return
+			}
+			if issue == nil {
+				t.Fatalf("parseBOD() succeeded, want an issue containing %q", tt.message)
+			}
+			if !strings.Contains(issue.Message, tt.message) {
+				t.Errorf("issue = %q, want it to contain %q", issue.Message, tt.message)
+			}
+			if tt.line > 0 && issue.Line != tt.line {
+				t.Errorf("issue on line %d, want line %d", issue.Line, tt.line)
+			}
+		})
+	}
+}
+
+func TestParseBODPositions(t *testing.T) {
+	root, issue := parseBOD(testBOD)
+	if issue != nil {
+		t.Fatalf("parseBOD() failed: %s", issue.Message)
+	}
+	created := root.child("ApplicationArea").child("CreationDateTime")
+	if created.Line != 5 || created.Column != 5 {
+		t.Errorf("CreationDateTime at %d:%d, want 5:5", created.Line, created.Column)
+	}
+	if created.Text != "2026-10-18T10:00:00Z" {
+		t.Errorf("CreationDateTime text = %q", created.Text)
+	}
+}
+
+func TestValidateBOD(t *testing.T) {
+	tests := []struct {
+		name     string
+		replace  [2]string
+		expect   bodExpectations
+		valid    bool
+		issue    string
+		severity string
+	}{
+		{name: "valid", valid: true},
+		{name: "expected tenant and logical ID", expect: bodExpectations{TenantID: "ACME_TST", LogicalID: "lid://infor.ims.test"}, valid: true},
+		{name: "other tenant", expect: bodExpectations{TenantID: "ACME_PRD"}, issue: "TenantID is ACME_TST, expected ACME_PRD", severity: bodError},
+		{name: "other logical ID", expect: bodExpectations{LogicalID: "lid://other"}, issue: "expected lid://other", severity: bodError},
+		{name: "date time without zone", replace: [2]string{"2026-10-18T10:00:00Z", "2026-10-18T10:00:00.123"}, valid: true},
+		{name: "date time with offset", replace: [2]string{"2026-10-18T10:00:00Z", "2026-10-18T10:00:00+02:00"}, valid: true},
+		{name: "date only", replace: [2]string{"2026-10-18T10:00:00Z", "2026-10-18"}, issue: "is not an xs:dateTime", severity: bodError},
+		{name: "placeholder", replace: [2]string{"A100", "${ITEM}"}, valid: true, issue: "placeholder ${ITEM} is not replaced", severity: bodWarning},
+		{name: "not a BOD name", replace: [2]string{"SyncItemMaster", "ItemMaster"}, issue: "is not a BOD name", severity: bodError},
+		{name: "wrong verb", replace: [2]string{"<Sync><TenantID>ACME_TST</TenantID></Sync>", "<Process/>"}, issue: "expected the verb Sync", severity: bodError},
+		{name: "wrong noun", replace: [2]string{"<ItemMaster><ItemMasterHeader><ItemID><ID>A100</ID></ItemID></ItemMasterHeader></ItemMaster>", "<Item/>"}, issue: "expected the noun ItemMaster", severity: bodError},
+		{name: "no sender", replace: [2]string{"<Sender><LogicalID>lid://infor.ims.test</LogicalID></Sender>", ""}, issue: "ApplicationArea has no Sender", severity: bodError},
+		{name: "logical ID without lid", replace: [2]string{"lid://infor.ims.test", "infor.ims.test"}, valid: true, issue: "does not start with lid://", severity: bodWarning},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document := testBOD
+			if tt.replace[0] != "" {
+				document = strings.ReplaceAll(document, tt.replace[0], tt.replace[1])
+			}
+			validation := validateBOD(document, tt.expect)
+			if validation.Valid != tt.valid {
+				t.Errorf("valid = %v, want %v, issues %+v", validation.Valid, tt.valid, validation.Issues)
+			}
+			if tt.issue == "" {
+				if len(validation.Issues) > 0 {
+					t.Errorf("unexpected issues %+v", validation.Issues)
+				}
+				return
+			}
+			for _, issue := range validation.Issues {
+				if strings.Contains(issue.Message, tt.issue) {
+					if issue.Severity != tt.severity {
+						t.Errorf("issue %q is a %s, want a %s", issue.Message, issue.Severity, tt.severity)
+					}
+					return
+				}
+			}
+			t.Errorf("no issue containing %q in %+v", tt.issue, validation.Issues)
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// callValueFlags are the flags of the call command that take a value
+var callValueFlags = []string{"--header", "--query", "--data", "--file", "--content-type", "--output", "--spec", "--op", "--param", "--base-path", "--policy", "--allow-write"}
+
+// runCall sends an authenticated request to any ION API: call <ionapi-file-path> <METHOD> <path>,
+// or call <ionapi-file-path> --spec <openapi.json> --op <operationId> [--param NAME=VALUE ...]
+func runCall(args []string) error {
+	cli := parseArgs(args, callValueFlags...)
+	withSpec := cli.has("--spec")
+	if withSpec && len(cli.positional) != 1 || !withSpec && len(cli.positional) < 3 {
+		return fmt.Errorf("usage: call <ionapi-file-path> <METHOD> <path> [--header 'Name: value' ...] [--query KEY=VALUE ...] [--data <json>|@<file>] [--file <file>] [--output <file>] [--include]\n" +
+			"       call <ionapi-file-path> --spec <openapi.json|gateway path> [--op <operationId> [--param NAME=VALUE ...]] [--base-path <path>]")
+	}
+	// Operation parameters are placed where the document declares them, only --param sets them
+	if withSpec && (cli.has("--query") || cli.has("--header")) {
+		return fmt.Errorf("--query and --header cannot be used with --spec, set the parameters of the operation with --param NAME=VALUE")
+	}
+	applyGlobalFlags(cli)
+	if err := configureM3Policy(cli); err != nil {
+		return err
+	}
+	// The response body is printed to stdout unless it is saved, keep it free of log output
+	if !cli.has("--output") {
+		logger.SetOutput(os.Stderr)
+	}
+
+	body, contentType, err := callBody(cli)
+	if err != nil {
+		return err
+	}
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newIonClient(ionAPI, token)
+
+	var req *http.Request
+	var spec *openAPISpec
+	var operation *openAPIOperation
+	if withSpec {
+		if spec, err = loadOpenAPISpec(client, cli.value("--spec", "")); err != nil {
+			return fmt.Errorf("failed to load OpenAPI document: %v", err)
+		}
+		if !cli.has("--op") {
+			return printOperations(spec)
+		}
+		if operation, err = spec.operation(cli.value("--op", "")); err != nil {
+			return err
+		}
+		var data []byte
+		if body != nil {
+			if data, err = ioutil.ReadAll(body); err != nil {
+				return err
+			}
+		}
+		req, err = newOperationRequest(client, spec, operation, cli.values("--param"), data, cli.value("--base-path", spec.basePath()))
+		if err != nil {
+			return err
+		}
+	} else if req, err = client.newRequest(strings.ToUpper(cli.positional[1]), cli.positional[2], body); err != nil {
+		return fmt.Errorf("Failed to create HTTP request: %v", err)
+	}
+	query := req.URL.Query()
+	for _, param := range cli.values("--query") {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("invalid query parameter %q, expected KEY=VALUE", param)
+		}
+		query.Add(key, value)
+	}
+	req.URL.RawQuery = query.Encode()
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, header := range cli.values("--header") {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid header %q, expected 'Name: value'", header)
+		}
+		req.Header.Set(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	writes, err := guardM3Call(ionAPI.TenantID, req)
+	if err != nil {
+		return err
+	}
+	resp, respBody, err := makeHTTPRequest(client, req)
+	auditM3Call(ionAPI.TenantID, writes, resp, respBody, err)
+	if err != nil {
+		return err
+	}
+
+	if cli.has("--include") {
+		fmt.Fprintf(logger.Writer(), "%s %s\n", resp.Proto, resp.Status)
+		resp.Header.Write(logger.Writer())
+		fmt.Fprintln(logger.Writer())
+	}
+	if output := cli.value("--output", ""); output != "" {
+		if err := ioutil.WriteFile(output, respBody, 0644); err != nil {
+			return err
+		}
+		logger.Printf("📝 Response (%d bytes) written to %s", len(respBody), output)
+	} else {
+		printResponseBody(os.Stdout, resp.Header.Get("Content-Type"), respBody)
+	}
+
+	var schemaErrors []string
+	if operation != nil {
+		schemaErrors = checkOperationResponse(spec, operation, resp, respBody)
+	}
+
+	finishReport()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("request failed with status: %s", resp.Status)
+	}
+	if len(schemaErrors) > 0 {
+		return fmt.Errorf("response does not match the schema of %s", operation.OperationID)
+	}
+	return nil
+}
+
+// makeHTTPRequest sends the request and logs its outcome, with the allowed methods when the method is not allowed
+func makeHTTPRequest(client *ionClient, req *http.Request) (*http.Response, []byte, error) {
+	resp, body, err := client.do(req, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+	if err != nil {
+		logger.Printf("❌ Error making HTTP request to %s: %v", req.URL, err)
+		return nil, nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusMethodNotAllowed:
+		logger.Printf("⚠️ 405 Method Not Allowed for URL: %s", req.URL)
+		logger.Printf("   HTTP Method used: %s", req.Method)
+		logger.Printf("   Allowed Methods: %s", resp.Header.Get("Allow"))
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		logger.Printf("⚠️ Unexpected response: %s for URL %s", resp.Status, req.URL)
+	default:
+		logger.Printf("✅ Request successful to %s (%s)", req.URL, resp.Status)
+	}
+	return resp, body, nil
+}
+
+// m3ExecutePath matches the gateway path of M3 transactions on the v1 and v2 endpoints, in any case,
+// with the program and transaction of a single transaction request, or none for a multi-transaction request
+var m3ExecutePath = regexp.MustCompile(`(?i)/M3/m3api-rest/(?:v2/)?execute(?:/([^/]+)(?:/([^/]+))?)?/?$`)
+
+// m3CallWrite is a write transaction sent with call, with the decision of the policy
+type m3CallWrite struct {
+	index       int
+	decision    string
+	program     string
+	transaction string
+	record      map[string]string
+}
+
+// guardM3Call checks the M3 transactions of a request to the M3 API against the write policy,
+// the same way as m3 call and m3 bulk. It returns the write transactions to audit.
+func guardM3Call(tenantID string, req *http.Request) ([]m3CallWrite, error) {
+	match := m3ExecutePath.FindStringSubmatch(req.URL.Path)
+	if match == nil {
+		return nil, nil
+	}
+	program := match[1]
+	var transactions []m3Transaction
+	if match[2] != "" {
+		record := map[string]string{}
+		for key, values := range req.URL.Query() {
+			record[key] = values[0]
+		}
+		transactions = []m3Transaction{{Transaction: match[2], Record: record}}
+	} else if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		var multi m3MultiRequest
+		if err := json.NewDecoder(body).Decode(&multi); err != nil {
+			return nil, fmt.Errorf("the M3 transactions of the request body can not be checked against the write policy (%v), use m3 bulk", err)
+		}
+		program, transactions = multi.Program, multi.Transactions
+	}
+
+	var writes []m3CallWrite
+	for i, transaction := range transactions {
+		decision, err := guardM3Write(tenantID, program, transaction.Transaction, transaction.Record)
+		if err != nil {
+			return nil, err
+		}
+		if decision != "" {
+			writes = append(writes, m3CallWrite{i, decision, program, transaction.Transaction, transaction.Record})
+		}
+	}
+	return writes, nil
+}
+
+// auditM3Call records the outcome of the write transactions of a request in the audit log
+func auditM3Call(tenantID string, writes []m3CallWrite, resp *http.Response, body []byte, err error) {
+	if len(writes) == 0 {
+		return
+	}
+	var response *m3Response
+	if err == nil && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		err = fmt.Errorf("API request failed with status: %s", resp.Status)
+	}
+	if err == nil {
+		response, err = parseM3Response(body)
+	}
+	for _, write := range writes {
+		resultErr := err
+		if err == nil && write.index < len(response.Results) {
+			resultErr = response.Results[write.index].Err(write.program)
+		} else if err == nil {
+			resultErr = fmt.Errorf("not processed")
+		}
+		auditM3Write(tenantID, write.decision, write.program, write.transaction, write.record, resultErr)
+	}
+}
+
+// callBody returns the request body of --data or --file and its default content type.
+// --data @file reads the JSON body from a file.
+func callBody(cli cliArgs) (io.Reader, string, error) {
+	contentType := cli.value("--content-type", "")
+	if data := cli.value("--data", ""); data != "" {
+		if strings.HasPrefix(data, "@") {
+			content, err := ioutil.ReadFile(data[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			data = string(content)
+		}
+		if contentType == "" {
+			if !json.Valid([]byte(data)) {
+				return nil, "", fmt.Errorf("--data is not valid JSON, set --content-type to send other content")
+			}
+			contentType = "application/json"
+		}
+		return strings.NewReader(data), contentType, nil
+	}
+	if file := cli.value("--file", ""); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, "", err
+		}
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(file))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		return bytes.NewReader(content), contentType, nil
+	}
+	return nil, contentType, nil
+}
+
+// printResponseBody writes the response body, indenting JSON
+func printResponseBody(w io.Writer, contentType string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	if strings.Contains(contentType, "json") || json.Valid(body) {
+		var indented bytes.Buffer
+		if err := json.Indent(&indented, body, "", "  "); err == nil {
+			fmt.Fprintln(w, indented.String())
+			return
+		}
+	}
+	fmt.Fprintln(w, string(body))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGuardM3Call(t *testing.T) {
+	dir := t.TempDir()
+	defer func(saved *m3Policy) { policy = saved }(policy)
+	policy = &m3Policy{
+		ProductionTenants: defaultM3Policy.ProductionTenants,
+		WritePrefixes:     defaultM3Policy.WritePrefixes,
+		AuditLog:          filepath.Join(dir, "audit.jsonl"),
+	}
+	// A regular file as stdin, so the policy does not ask for a confirmation
+	stdin, err := os.Create(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved *os.File) { os.Stdin = saved }(os.Stdin)
+	os.Stdin = stdin
+
+	tests := []struct {
+		name    string
+		tenant  string
+		method  string
+		path    string
+		body    string
+		writes  int
+		blocked bool
+	}{
+		{name: "v2 write", tenant: "ACME_PRD", method: "GET", path: "/M3/m3api-rest/v2/execute/MMS200MI/AddItmBasic?ITNO=A1", blocked: true},
+		{name: "v1 write", tenant: "ACME_PRD", method: "POST", path: "/M3/m3api-rest/execute/MMS200MI/AddItmBasic", blocked: true},
+		{name: "lower-case write", tenant: "ACME_PRD", method: "GET", path: "/m3/M3API-REST/v2/execute/mms200mi/additmbasic", blocked: true},
+		{name: "multi-transaction write", tenant: "ACME_PRD", method: "POST", path: "/M3/m3api-rest/v2/execute",
+			body: `{"program": "MMS200MI", "transactions": [{"transaction": "GetItmBasic"}, {"transaction": "UpdItmBasic"}]}`, blocked: true},
+		{name: "unreadable multi-transaction body", tenant: "ACME_PRD", method: "POST", path: "/M3/m3api-rest/v2/execute", body: `ITNO=A1`, blocked: true},
+		{name: "read", tenant: "ACME_PRD", method: "GET", path: "/M3/m3api-rest/v2/execute/MMS200MI/GetItmBasic"},
+		{name: "write on a test tenant", tenant: "ACME_TST", method: "GET", path: "/M3/m3api-rest/execute/MMS200MI/AddItmBasic", writes: 1},
+		{name: "not M3", tenant: "ACME_PRD", method: "POST", path: "/IFS/usermgt/v2/users/AddUser"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			req, err := http.NewRequest(tt.method, "https://gateway.example.com/"+tt.tenant+tt.path, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			writes, err := guardM3Call(tt.tenant, req)
+			if tt.blocked {
+				if err == nil {
+					t.Fatalf("guardM3Call() let %s %s through", tt.method, tt.path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("guardM3Call() failed: %v", err)
+			}
+			if len(writes) != tt.writes {
+				t.Errorf("guardM3Call() returned %d write(s), want %d", len(writes), tt.writes)
+			}
+		})
+	}
+
+	audit, err := ioutil.ReadFile(policy.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(audit), `"outcome":"blocked"`); got != 4 {
+		t.Errorf("audit log has %d blocked entries, want 4:\n%s", got, audit)
+	}
+}
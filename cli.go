@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// globalValueFlags are the flags shared by every command that take a value
+var globalValueFlags = []string{"--report", "--resolve"}
+
+// cliArgs holds the positional arguments and flags of a command line
+type cliArgs struct {
+	positional []string
+	flags      map[string][]string
+}
+
+// parseArgs splits args into positional arguments and flags. Flags listed in valueFlags
+// take a value, given as "--flag value" or "--flag=value", all others are boolean.
+func parseArgs(args []string, valueFlags ...string) cliArgs {
+	parsed := cliArgs{flags: map[string][]string{}}
+	takesValue := map[string]bool{}
+	for _, name := range append(valueFlags, globalValueFlags...) {
+		takesValue[name] = true
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			parsed.positional = append(parsed.positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if takesValue[name] && !hasValue && i+1 < len(args) {
+			i++
+			value, hasValue = args[i], true
+		}
+		if !hasValue {
+			value = "true"
+		}
+		parsed.flags[name] = append(parsed.flags[name], value)
+	}
+	return parsed
+}
+
+// has reports whether the flag was given
+func (a cliArgs) has(name string) bool {
+	return len(a.flags[name]) > 0
+}
+
+// value returns the last value of the flag, or def if it was not given
+func (a cliArgs) value(name, def string) string {
+	if values := a.flags[name]; len(values) > 0 {
+		return values[len(values)-1]
+	}
+	return def
+}
+
+// values returns every value given for a repeatable flag
+func (a cliArgs) values(name string) []string {
+	return a.flags[name]
+}
+
+// intValue returns the flag parsed as an integer, or def if it was not given
+func (a cliArgs) intValue(name string, def int) (int, error) {
+	if !a.has(name) {
+		return def, nil
+	}
+	n, err := strconv.Atoi(a.value(name, ""))
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %v", name, err)
+	}
+	return n, nil
+}
+
+// boolValue returns the flag parsed as a boolean, or def if it was not given. Use --flag=false to disable.
+func (a cliArgs) boolValue(name string, def bool) (bool, error) {
+	if !a.has(name) {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(a.value(name, ""))
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: %v", name, err)
+	}
+	return b, nil
+}
+
+// applyGlobalFlags sets the options shared by every command: --debug, --report and --resolve
+func applyGlobalFlags(a cliArgs) {
+	if a.has("--debug") {
+		debugMode = true
+		log.Println("Debug mode enabled")
+	}
+	reportFile = a.value("--report", "")
+	for _, entry := range a.values("--resolve") {
+		if err := addResolveOverride(entry); err != nil {
+			fatalf("%v", err)
+		}
+	}
+	installResolveOverrides()
+}
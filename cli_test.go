@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		positional []string
+		flags      map[string][]string
+	}{
+		{
+			name:       "positional only",
+			args:       []string{"file.ionapi", "GET", "/path"},
+			positional: []string{"file.ionapi", "GET", "/path"},
+			flags:      map[string][]string{},
+		},
+		{
+			name:       "value flag with space",
+			args:       []string{"file.ionapi", "--format", "json"},
+			positional: []string{"file.ionapi"},
+			flags:      map[string][]string{"--format": {"json"}},
+		},
+		{
+			name:       "value flag with equals",
+			args:       []string{"--format=csv", "file.ionapi"},
+			positional: []string{"file.ionapi"},
+			flags:      map[string][]string{"--format": {"csv"}},
+		},
+		{
+			name:       "boolean flag does not take the next argument",
+			args:       []string{"--debug", "file.ionapi"},
+			positional: []string{"file.ionapi"},
+			flags:      map[string][]string{"--debug": {"true"}},
+		},
+		{
+			name:       "repeated flag",
+			args:       []string{"--param", "A=1", "--param=B=2"},
+			positional: nil,
+			flags:      map[string][]string{"--param": {"A=1", "B=2"}},
+		},
+		{
+			name:       "value flag at the end",
+			args:       []string{"file.ionapi", "--format"},
+			positional: []string{"file.ionapi"},
+			flags:      map[string][]string{"--format": {"true"}},
+		},
+		{
+			name:       "global value flags",
+			args:       []string{"--report", "r.json", "--resolve", "host:443:10.0.0.1"},
+			positional: nil,
+			flags:      map[string][]string{"--report": {"r.json"}, "--resolve": {"host:443:10.0.0.1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseArgs(tt.args, "--format", "--param")
+			if !reflect.DeepEqual(got.positional, tt.positional) {
+				t.Errorf("positional = %q, want %q", got.positional, tt.positional)
+			}
+			if !reflect.DeepEqual(got.flags, tt.flags) {
+				t.Errorf("flags = %q, want %q", got.flags, tt.flags)
+			}
+		})
+	}
+}
+
+func TestCLIArgsValues(t *testing.T) {
+	cli := parseArgs([]string{"--size", "10", "--size", "20", "--bad", "x", "--keep"}, "--size", "--bad")
+	if got := cli.value("--size", ""); got != "20" {
+		t.Errorf("value(--size) = %q, want the last value 20", got)
+	}
+	if got := cli.value("--missing", "def"); got != "def" {
+		t.Errorf("value(--missing) = %q, want the default", got)
+	}
+	if n, err := cli.intValue("--size", 0); err != nil || n != 20 {
+		t.Errorf("intValue(--size) = %d, %v, want 20", n, err)
+	}
+	if n, err := cli.intValue("--missing", 5); err != nil || n != 5 {
+		t.Errorf("intValue(--missing) = %d, %v, want the default 5", n, err)
+	}
+	if _, err := cli.intValue("--bad", 0); err == nil {
+		t.Error("intValue(--bad) succeeded for a value that is not a number")
+	}
+	if !cli.has("--keep") || cli.has("--missing") {
+		t.Error("has() does not report the given flags")
+	}
+}
@@ -1,28 +1,236 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+// Classification of a probe response
+const (
+	probeReachable    = "reachable"
+	probeAuthRequired = "reachable (authentication required)"
+	probeClientError  = "reachable (client error)"
+	probeServerError  = "server error"
+	probeBlocked      = "blocked by proxy"
+	probeUnreachable  = "unreachable"
+)
+
+// maxProbeRedirects is the number of redirects followed before the probe gives up
+const maxProbeRedirects = 10
+
+// blockPageMarkers are snippets found in the block pages of common proxies and web filters. Generic
+// phrases such as "access denied" are left out, the 401 and 403 pages of the gateway use them too.
+var blockPageMarkers = []string{
+	"access to this site is blocked",
+	"web page blocked",
+	"the page you are trying to access is blocked",
+	"url blocked",
+	"zscaler",
+	"websense",
+	"forcepoint",
+	"bluecoat",
+	"blue coat",
+	"fortiguard",
+	"mcafee web gateway",
+	"palo alto networks",
+	"sophos web appliance",
+	"generated by squid",
+}
+
+// gatewayMarkers are snippets of the Server and WWW-Authenticate headers of the Infor ION API Gateway
+// and STS. A response with one of them comes from Infor and is never a proxy block page.
+var gatewayMarkers = []string{
+	"infor ion",
+	"infor os",
+	"ion api",
+	"ionapi",
+	"inforcloudsuite",
+	"mingle",
+}
+
+// redirectHop is a single step in a redirect chain
+type redirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+// probeResult is the outcome of probing an HTTP endpoint
+type probeResult struct {
+	ServiceName    string        `json:"service"`
+	URL            string        `json:"url"`
+	Method         string        `json:"method"`
+	StatusCode     int           `json:"status_code,omitempty"`
+	Classification string        `json:"classification"`
+	Reachable      bool          `json:"reachable"`
+	Redirects      []redirectHop `json:"redirects,omitempty"`
+	FinalURL       string        `json:"final_url,omitempty"`
+	AllowedMethods string        `json:"allowed_methods,omitempty"`
+	Fingerprint    string        `json:"fingerprint,omitempty"`
+	Detail         string        `json:"detail,omitempty"`
+	Error          string        `json:"error,omitempty"`
+}
+
 // checkConnectivity checks if the application can connect to the provided URL
 func checkConnectivity(url string, serviceName string) bool {
+	result := probeEndpoint(url, serviceName)
+	report.addProbe(result)
+
+	for _, hop := range result.Redirects {
+		logger.Printf("↪️  %s redirected %d: %s -> %s", serviceName, hop.StatusCode, hop.URL, hop.Location)
+	}
+	if result.Fingerprint != "" {
+		logger.Printf("🔎 %s server fingerprint: %s", serviceName, result.Fingerprint)
+	}
+
+	if !result.Reachable {
+		log.Printf("❌ Cannot reach %s (%s): %s, Status Code: %d %s", serviceName, url, result.Classification, result.StatusCode, result.Detail)
+		if result.Error != "" {
+			log.Printf("   Error: %s", result.Error)
+		}
+		return false
+	}
+
+	log.Printf("✅ Successfully connected to %s (%s): %s, Status Code: %d %s", serviceName, url, result.Classification, result.StatusCode, result.Detail)
+	return true
+}
+
+// probeEndpoint sends a HEAD request to the URL, falling back to GET when the server does not
+// allow HEAD, and classifies the response. Redirects are followed and recorded.
+func probeEndpoint(rawURL string, serviceName string) probeResult {
+	result := probeResult{ServiceName: serviceName, URL: rawURL, Method: http.MethodHead}
+
+	resp, body, err := sendProbe(http.MethodHead, rawURL, serviceName, &result)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		result.AllowedMethods = resp.Header.Get("Allow")
+		debugPrint("⚠️ HEAD returned %d for %s (Allow: %s), retrying with GET", resp.StatusCode, rawURL, result.AllowedMethods)
+		result.Method = http.MethodGet
+		result.Redirects = nil
+		resp, body, err = sendProbe(http.MethodGet, rawURL, serviceName, &result)
+	}
+	if err != nil {
+		result.Classification = probeUnreachable
+		result.Error = err.Error()
+		return result
+	}
+
+	result.StatusCode = resp.StatusCode
+	result.FinalURL = resp.Request.URL.String()
+	result.Fingerprint = serverFingerprint(resp.Header)
+	classifyProbe(&result, resp, body)
+	return result
+}
+
+// sendProbe performs a single probe request and returns the response with the start of its body
+func sendProbe(method, rawURL, serviceName string, result *probeResult) (*http.Response, []byte, error) {
 	client := &http.Client{
 		Timeout: 5 * time.Second, // Set a timeout of 5 seconds
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxProbeRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxProbeRedirects)
+			}
+			hop := redirectHop{URL: via[len(via)-1].URL.String(), Location: req.URL.String()}
+			if req.Response != nil {
+				hop.StatusCode = req.Response.StatusCode
+			}
+			result.Redirects = append(result.Redirects, hop)
+			return nil
+		},
 	}
-	req, err := http.NewRequest("HEAD", url, nil)
+
+	req, err := http.NewRequest(method, rawURL, nil)
 	if err != nil {
-		log.Printf("❌ Failed to create request for %s: %v", serviceName, err)
-		return false
+		return nil, nil, fmt.Errorf("failed to create request for %s: %v", serviceName, err)
 	}
+	req, timing := withTiming(req, fmt.Sprintf("%s probe (%s)", serviceName, method))
 
 	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 400 {
-		log.Printf("❌ Cannot reach %s. Error: %v, Status Code: %d", serviceName, err, resp.StatusCode)
-		return false
+	if err != nil {
+		timing.fail(err)
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	log.Printf("✅ Successfully connected to %s (%s)", serviceName, url)
-	return true
+	// Block pages are identified from the first part of the body, there is no need to read more
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	timing.finish()
+	return resp, body, nil
+}
+
+// classifyProbe decides whether the response proves the service is reachable
+func classifyProbe(result *probeResult, resp *http.Response, body []byte) {
+	if marker := blockPageMarker(resp, body); marker != "" {
+		result.Classification = probeBlocked
+		result.Detail = fmt.Sprintf("(response looks like a proxy block page: %q)", marker)
+		return
+	}
+	if len(result.Redirects) > 0 && hostOf(result.FinalURL) != hostOf(result.URL) {
+		result.Detail = fmt.Sprintf("(redirected to %s)", hostOf(result.FinalURL))
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusProxyAuthRequired:
+		result.Classification = probeBlocked
+		result.Detail = "(proxy authentication required)"
+	case resp.StatusCode < 400:
+		result.Classification = probeReachable
+		result.Reachable = true
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		// The gateway rejects requests without a bearer token, which proves it is reachable
+		result.Classification = probeAuthRequired
+		result.Reachable = true
+	case resp.StatusCode < 500:
+		result.Classification = probeClientError
+		result.Reachable = true
+	default:
+		result.Classification = probeServerError
+	}
+}
+
+// blockPageMarker returns the block page marker found in an HTML response that does not come from
+// the gateway or STS, if any. Only the Server and WWW-Authenticate headers identify the gateway:
+// block pages echo the blocked URL, so the body and the other headers name the gateway host too.
+func blockPageMarker(resp *http.Response, body []byte) string {
+	if !strings.Contains(strings.ToLower(resp.Header.Get("Content-Type")), "html") {
+		return ""
+	}
+	lowerBody := strings.ToLower(string(body))
+	lowerHeaders := strings.ToLower(resp.Header.Get("Server") + " " + resp.Header.Get("WWW-Authenticate"))
+	for _, marker := range gatewayMarkers {
+		if strings.Contains(lowerHeaders, marker) {
+			return ""
+		}
+	}
+	for _, marker := range blockPageMarkers {
+		if strings.Contains(lowerBody, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// serverFingerprint summarises the headers that identify the server and any intermediaries
+func serverFingerprint(header http.Header) string {
+	var parts []string
+	for _, name := range []string{"Server", "Via", "X-Powered-By", "X-Cache", "X-Amzn-Trace-Id", "CF-RAY", "X-Zscaler-Transaction-Id"} {
+		if value := header.Get(name); value != "" {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// hostOf returns the host of a URL, or an empty string if it cannot be parsed
+func hostOf(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return parsedURL.Host
 }
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBlockPageMarker(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   string
+	}{
+		{
+			name:   "zscaler page echoing the gateway URL",
+			header: http.Header{"Content-Type": {"text/html"}},
+			body:   `<html><title>Zscaler</title>Access to https://mingle-ionapi.eu1.inforcloudsuite.com/ACME_TST is not allowed</html>`,
+			want:   "zscaler",
+		},
+		{
+			name:   "block page with a gateway host in Via",
+			header: http.Header{"Content-Type": {"text/html"}, "Via": {"1.1 mingle-ionapi.inforcloudsuite.com"}},
+			body:   `<h1>Web Page Blocked</h1>`,
+			want:   "web page blocked",
+		},
+		{
+			name:   "gateway page naming a proxy product",
+			header: http.Header{"Content-Type": {"text/html"}, "Server": {"Infor ION API Gateway"}},
+			body:   `<h1>403</h1>Requests through zscaler need a token`,
+		},
+		{
+			name:   "STS challenge",
+			header: http.Header{"Content-Type": {"text/html"}, "Www-Authenticate": {`Bearer realm="mingle"`}},
+			body:   `url blocked`,
+		},
+		{
+			name:   "gateway 403 page",
+			header: http.Header{"Content-Type": {"text/html"}},
+			body:   `<h1>403 Forbidden</h1>Access denied`,
+		},
+		{
+			name:   "not HTML",
+			header: http.Header{"Content-Type": {"application/json"}},
+			body:   `{"message": "zscaler"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockPageMarker(&http.Response{Header: tt.header}, []byte(tt.body)); got != tt.want {
+				t.Errorf("blockPageMarker() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeEndpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/get-only", http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/blocked", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<title>Zscaler</title>Access to mingle-ionapi.inforcloudsuite.com is blocked`))
+	})
+	mux.HandleFunc("/proxy-auth", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	})
+	mux.HandleFunc("/down", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		path           string
+		method         string
+		classification string
+		reachable      bool
+		redirects      int
+	}{
+		{"/get-only", http.MethodGet, probeReachable, true, 0},
+		{"/old", http.MethodGet, probeReachable, true, 2},
+		{"/token", http.MethodHead, probeAuthRequired, true, 0},
+		{"/missing", http.MethodHead, probeClientError, true, 0},
+		{"/blocked", http.MethodGet, probeBlocked, false, 0},
+		{"/proxy-auth", http.MethodHead, probeBlocked, false, 0},
+		{"/down", http.MethodHead, probeServerError, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			result := probeEndpoint(server.URL+tt.path, "test")
+			if result.Method != tt.method || result.Classification != tt.classification || result.Reachable != tt.reachable {
+				t.Errorf("probeEndpoint() = %s %s (reachable %v), want %s %s (reachable %v)", result.Method,
+					result.Classification, result.Reachable, tt.method, tt.classification, tt.reachable)
+			}
+			if len(result.Redirects) != tt.redirects {
+				t.Errorf("probeEndpoint() recorded redirects %+v, want %d", result.Redirects, tt.redirects)
+			}
+		})
+	}
+}
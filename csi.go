@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csiIDOPath is the gateway path of the CSI (Mongoose) IDO request service
+const csiIDOPath = "/CSI/IDORequestService/ido"
+
+// csiCommands maps the subcommands of the csi command to the functions running them
+var csiCommands = map[string]func(args []string) error{
+	"load":   runCSILoad,
+	"invoke": runCSIInvoke,
+}
+
+// idoResponse is the common part of the IDO request service responses
+type idoResponse struct {
+	Success     bool                     `json:"Success"`
+	Message     string                   `json:"Message"`
+	Items       []map[string]interface{} `json:"Items"`
+	ReturnValue string                   `json:"ReturnValue"`
+	Parameters  []interface{}            `json:"Parameters"`
+}
+
+// runCSI runs a csi subcommand
+func runCSI(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: csi <load|invoke> [arguments], see the usage of Infor-test")
+	}
+	command, ok := csiCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown csi subcommand %q", args[0])
+	}
+	return command(args[1:])
+}
+
+// runCSILoad loads a collection of an IDO: csi load <ionapi-file-path> <IDO> --properties A,B [--filter <filter>] [--record-cap <n>]
+func runCSILoad(args []string) error {
+	cli := parseArgs(args, "--config", "--properties", "--filter", "--record-cap", "--format")
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: csi load <ionapi-file-path> <IDO> [--properties A,B] [--filter <filter>] [--record-cap <n>] [--config <configuration>] [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	format := cli.value("--format", "table")
+	reserveStdout(format)
+	recordCap, err := cli.intValue("--record-cap", 100)
+	if err != nil {
+		return err
+	}
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newIonClient(ionAPI, token)
+	config, err := csiConfiguration(client, cli.value("--config", ""))
+	if err != nil {
+		return err
+	}
+	columns, records, err := loadIDOCollection(client, config, cli.positional[1], cli.value("--properties", "*"), cli.value("--filter", ""), recordCap)
+	if err != nil {
+		return err
+	}
+	logger.Printf("✅ %d record(s) loaded from %s", len(records), cli.positional[1])
+	if err := printRecords(os.Stdout, format, columns, records); err != nil {
+		return err
+	}
+	finishReport()
+	return nil
+}
+
+// runCSIInvoke invokes an IDO method: csi invoke <ionapi-file-path> <IDO> <method> [--param <value> ...]
+func runCSIInvoke(args []string) error {
+	cli := parseArgs(args, "--config", "--param")
+	if len(cli.positional) < 3 {
+		return fmt.Errorf("usage: csi invoke <ionapi-file-path> <IDO> <method> [--param <value> ...] [--config <configuration>]")
+	}
+	applyGlobalFlags(cli)
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newIonClient(ionAPI, token)
+	config, err := csiConfiguration(client, cli.value("--config", ""))
+	if err != nil {
+		return err
+	}
+	result, err := invokeIDOMethod(client, config, cli.positional[1], cli.positional[2], cli.values("--param"))
+	if err != nil {
+		return err
+	}
+	logger.Printf("✅ %s.%s returned %s", cli.positional[1], cli.positional[2], result.ReturnValue)
+	for i, param := range result.Parameters {
+		fmt.Printf("  Parameter %d: %v\n", i+1, param)
+	}
+	finishReport()
+	return nil
+}
+
+// csiConfiguration returns the configuration to use, the first one of the tenant when none is given
+func csiConfiguration(client *ionClient, config string) (string, error) {
+	if config != "" {
+		return config, nil
+	}
+	var configurations interface{}
+	if err := client.getJSON(csiIDOPath+"/configurations", "CSI configurations", &configurations); err != nil {
+		return "", fmt.Errorf("failed to list CSI configurations: %v", err)
+	}
+	names := jsonNames(configurations, "Configurations", "Name", "ConfigName")
+	if len(names) == 0 {
+		return "", fmt.Errorf("no CSI configurations available to this user")
+	}
+	logger.Printf("Using CSI configuration %s of %d", names[0], len(names))
+	return names[0], nil
+}
+
+// loadIDOCollection loads records of an IDO with the properties, an optional filter and a record cap
+func loadIDOCollection(client *ionClient, config, ido, properties, filter string, recordCap int) ([]column, []map[string]string, error) {
+	query := url.Values{}
+	query.Set("properties", properties)
+	query.Set("recordCap", strconv.Itoa(recordCap))
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	req, err := client.newRequest("GET", csiIDOPath+"/load/"+url.PathEscape(ido)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("X-Infor-MongooseConfig", config)
+	var response idoResponse
+	if err := client.doJSON(req, "CSI load "+ido, &response); err != nil {
+		return nil, nil, err
+	}
+	if !response.Success && response.Message != "" {
+		return nil, nil, fmt.Errorf("loading %s failed: %s", ido, response.Message)
+	}
+
+	var names []string
+	if properties != "*" {
+		names = strings.Split(properties, ",")
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		seen[name] = true
+	}
+	records := make([]map[string]string, len(response.Items))
+	for i, item := range response.Items {
+		records[i] = map[string]string{}
+		var extra []string
+		for name, value := range item {
+			if strings.HasPrefix(name, "_") {
+				continue
+			}
+			if value != nil {
+				records[i][name] = fmt.Sprint(value)
+			}
+			if !seen[name] {
+				seen[name] = true
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		names = append(names, extra...)
+	}
+
+	columns := make([]column, len(names))
+	for i, name := range names {
+		columns[i] = column{Name: name}
+	}
+	return columns, records, nil
+}
+
+// invokeIDOMethod invokes a method of an IDO with positional parameters
+func invokeIDOMethod(client *ionClient, config, ido, method string, params []string) (*idoResponse, error) {
+	if params == nil {
+		params = []string{}
+	}
+	body, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := client.newRequest("POST", csiIDOPath+"/invoke/"+url.PathEscape(ido)+"?method="+url.QueryEscape(method), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Infor-MongooseConfig", config)
+	var response idoResponse
+	if err := client.doJSON(req, "CSI invoke "+ido+"."+method, &response); err != nil {
+		return nil, err
+	}
+	if !response.Success && response.Message != "" {
+		return nil, fmt.Errorf("%s.%s failed: %s", ido, method, response.Message)
+	}
+	return &response, nil
+}
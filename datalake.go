@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Gateway paths of the Data Lake APIs
+const (
+	compassJobsPath   = "/DATAFABRIC/compass/v2/jobs/"
+	dataCatalogPath   = "/DATAFABRIC/datacatalog/v1/object/list"
+	compassMaxResults = 100000
+)
+
+// dataLakeCommands maps the subcommands of the datalake command to the functions running them
+var dataLakeCommands = map[string]func(args []string) error{
+	"check": runDataLakeCheck,
+	"query": runDataLakeQuery,
+}
+
+// dataLakeSummary is the outcome of a Data Lake command, added to the report
+type dataLakeSummary struct {
+	Objects    []string `json:"objects,omitempty"`
+	QueryID    string   `json:"query_id,omitempty"`
+	Status     string   `json:"status,omitempty"`
+	Rows       int      `json:"rows,omitempty"`
+	Pages      int      `json:"pages,omitempty"`
+	Output     string   `json:"output,omitempty"`
+	DurationMS float64  `json:"duration_ms,omitempty"`
+}
+
+// compassJob is the status of an asynchronous Compass query
+type compassJob struct {
+	QueryID string `json:"queryId"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// runDataLake runs a datalake subcommand
+func runDataLake(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: datalake <check|query> [arguments], see the usage of Infor-test")
+	}
+	command, ok := dataLakeCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown datalake subcommand %q", args[0])
+	}
+	return command(args[1:])
+}
+
+// runDataLakeCheck verifies access to the Data Lake by listing its objects: datalake check <ionapi-file-path>
+func runDataLakeCheck(args []string) error {
+	cli := parseArgs(args)
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: datalake check <ionapi-file-path>")
+	}
+	applyGlobalFlags(cli)
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	var objects interface{}
+	if err := newIonClient(ionAPI, token).getJSON(dataCatalogPath, "Data Catalog objects", &objects); err != nil {
+		return fmt.Errorf("failed to list Data Lake objects: %v", err)
+	}
+	names := jsonNames(objects, "objects", "name", "objectName")
+	report.DataLake = &dataLakeSummary{Objects: names}
+
+	logger.Printf("✅ Data Lake is accessible, %d object(s) available", len(names))
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+	finishReport()
+	return nil
+}
+
+// runDataLakeQuery submits a Compass SQL query, waits for it and writes the result:
+// datalake query <ionapi-file-path> <SQL>|--sql-file <file> [--output <file>] [--page-size <n>]
+func runDataLakeQuery(args []string) error {
+	cli := parseArgs(args, "--sql-file", "--output", "--page-size", "--timeout", "--poll")
+	if len(cli.positional) < 1 || (len(cli.positional) < 2 && !cli.has("--sql-file")) {
+		return fmt.Errorf("usage: datalake query <ionapi-file-path> <SQL>|--sql-file <file> [--output <file.csv|file.jsonl>] [--page-size <n>] [--timeout <d>] [--poll <d>]")
+	}
+	applyGlobalFlags(cli)
+
+	sql := strings.Join(cli.positional[1:], " ")
+	if file := cli.value("--sql-file", ""); file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		sql = string(content)
+	}
+	pageSize, err := cli.intValue("--page-size", 10000)
+	if err != nil {
+		return err
+	}
+	if pageSize < 1 || pageSize > compassMaxResults {
+		return fmt.Errorf("--page-size must be between 1 and %d", compassMaxResults)
+	}
+	timeout, err := time.ParseDuration(cli.value("--timeout", "10m"))
+	if err != nil {
+		return fmt.Errorf("invalid value for --timeout: %v", err)
+	}
+	poll, err := time.ParseDuration(cli.value("--poll", "2s"))
+	if err != nil {
+		return fmt.Errorf("invalid value for --poll: %v", err)
+	}
+
+	output := cli.value("--output", "")
+	var out io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	} else {
+		// The result is printed to stdout, keep it free of log output
+		logger.SetOutput(os.Stderr)
+	}
+	asCSV := strings.EqualFold(filepath.Ext(output), ".csv")
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newIonClient(ionAPI, token)
+
+	start := time.Now()
+	job, err := submitCompassQuery(client, sql)
+	if err != nil {
+		return err
+	}
+	summary := &dataLakeSummary{QueryID: job.QueryID, Output: output}
+	report.DataLake = summary
+	logger.Printf("📨 Compass query %s submitted", job.QueryID)
+
+	if job, err = waitForCompassQuery(client, job, timeout, poll); err != nil {
+		summary.Status = job.Status
+		return err
+	}
+	summary.Status = job.Status
+	logger.Printf("✅ Compass query %s finished in %s", job.QueryID, roundDuration(time.Since(start)))
+
+	for offset := 0; ; offset += pageSize {
+		page, err := compassResultPage(client, job.QueryID, offset, pageSize, asCSV)
+		if err != nil {
+			return err
+		}
+		rows, data, err := compassPageRows(page, asCSV, offset > 0)
+		if err != nil {
+			return err
+		}
+		if _, err := out.Write(data); err != nil {
+			return err
+		}
+		summary.Pages++
+		summary.Rows += rows
+		logger.Printf("📦 Page %d: %d row(s), %d total", summary.Pages, rows, summary.Rows)
+		if rows < pageSize {
+			break
+		}
+	}
+	summary.DurationMS = milliseconds(time.Since(start))
+
+	if output != "" {
+		logger.Printf("📝 %d row(s) written to %s", summary.Rows, output)
+	}
+	finishReport()
+	return nil
+}
+
+// submitCompassQuery submits the SQL statement as an asynchronous Compass job
+func submitCompassQuery(client *ionClient, sql string) (compassJob, error) {
+	var job compassJob
+	req, err := client.newRequest("POST", compassJobsPath, strings.NewReader(sql))
+	if err != nil {
+		return job, err
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	if err := client.doJSON(req, "Compass submit", &job); err != nil {
+		return job, fmt.Errorf("failed to submit Compass query: %v", err)
+	}
+	if job.QueryID == "" {
+		return job, fmt.Errorf("Compass did not return a query ID")
+	}
+	return job, nil
+}
+
+// waitForCompassQuery polls the job status until it is finished, failed or the timeout expires
+func waitForCompassQuery(client *ionClient, job compassJob, timeout, poll time.Duration) (compassJob, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		switch strings.ToUpper(job.Status) {
+		case "FINISHED", "COMPLETED":
+			return job, nil
+		case "FAILED", "CANCELLED", "CANCELED", "TIMEOUT":
+			return job, fmt.Errorf("Compass query %s %s: %s", job.QueryID, strings.ToLower(job.Status), job.Message)
+		}
+		if time.Now().After(deadline) {
+			return job, fmt.Errorf("Compass query %s is still %s after %s", job.QueryID, strings.ToLower(job.Status), timeout)
+		}
+
+		time.Sleep(poll)
+		var status compassJob
+		if err := client.getJSON(compassJobsPath+url.PathEscape(job.QueryID)+"/status/", "Compass status", &status); err != nil {
+			return job, fmt.Errorf("failed to get status of Compass query %s: %v", job.QueryID, err)
+		}
+		job.Status, job.Message = status.Status, status.Message
+		debugPrint("Compass query %s is %s", job.QueryID, job.Status)
+	}
+}
+
+// compassResultPage downloads a page of the result as CSV or JSON lines
+func compassResultPage(client *ionClient, queryID string, offset, limit int, asCSV bool) ([]byte, error) {
+	path := fmt.Sprintf("%s%s/result/?offset=%d&limit=%d", compassJobsPath, url.PathEscape(queryID), offset, limit)
+	req, err := client.newRequest("GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/x-ndjson")
+	if asCSV {
+		req.Header.Set("accept", "text/csv")
+	}
+
+	resp, body, err := client.do(req, fmt.Sprintf("Compass result (offset %d)", offset))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, &httpStatusError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+	return body, nil
+}
+
+// compassPageRows counts the rows of a result page and returns the data to write. The CSV header
+// is dropped from every page but the first.
+func compassPageRows(page []byte, asCSV, dropHeader bool) (int, []byte, error) {
+	if len(bytes.TrimSpace(page)) == 0 {
+		return 0, nil, nil
+	}
+	if !bytes.HasSuffix(page, []byte("\n")) {
+		page = append(page, '\n')
+	}
+	if !asCSV {
+		return bytes.Count(bytes.TrimSpace(page), []byte("\n")) + 1, page, nil
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(page)).ReadAll()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to read CSV result: %v", err)
+	}
+	rows := len(records) - 1
+	if dropHeader {
+		// The header is the first line, none of its fields contain line breaks
+		page = page[bytes.IndexByte(page, '\n')+1:]
+	}
+	return rows, page, nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCompassPageRows(t *testing.T) {
+	tests := []struct {
+		name       string
+		page       string
+		asCSV      bool
+		dropHeader bool
+		rows       int
+		data       string
+	}{
+		{name: "empty", page: " \n", rows: 0, data: ""},
+		{name: "JSON lines", page: "{\"a\":1}\n{\"a\":2}", rows: 2, data: "{\"a\":1}\n{\"a\":2}\n"},
+		{name: "first CSV page", page: "ITNO,ITDS\nA1,Bolt\nA2,Nut\n", asCSV: true, rows: 2, data: "ITNO,ITDS\nA1,Bolt\nA2,Nut\n"},
+		{name: "next CSV page", page: "ITNO,ITDS\nA3,\"Washer\nlarge\"", asCSV: true, dropHeader: true, rows: 1, data: "A3,\"Washer\nlarge\"\n"},
+		{name: "header only", page: "ITNO,ITDS\n", asCSV: true, dropHeader: true, rows: 0, data: ""},
+	}
+	for _, tt := range tests {
+		rows, data, err := compassPageRows([]byte(tt.page), tt.asCSV, tt.dropHeader)
+		if err != nil || rows != tt.rows || string(data) != tt.data {
+			t.Errorf("%s: compassPageRows() = %d, %q, %v, want %d, %q", tt.name, rows, data, err, tt.rows, tt.data)
+		}
+	}
+	if _, _, err := compassPageRows([]byte("a,b\n\"c"), true, false); err == nil {
+		t.Error("compassPageRows() accepted broken CSV")
+	}
+}
+
+// compassGateway is a fake Compass API with three result rows. Queries containing FAIL fail, the
+// status is RUNNING on the first poll.
+func compassGateway(t *testing.T) *testGateway {
+	rows := []string{"A1,Bolt", "A2,Nut", "A3,Washer"}
+	var polls int32
+	failed := map[string]bool{}
+	return newTestGateway(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/ACME_TST"+compassJobsPath)
+		switch {
+		case r.Method == "POST" && path == "":
+			sql, _ := ioutil.ReadAll(r.Body)
+			id := fmt.Sprintf("q%d", len(failed)+1)
+			failed[id] = strings.Contains(string(sql), "FAIL")
+			fmt.Fprintf(w, `{"queryId": %q, "status": "RUNNING"}`, id)
+		case strings.HasSuffix(path, "/status/"):
+			id := strings.TrimSuffix(path, "/status/")
+			switch {
+			case atomic.AddInt32(&polls, 1) == 1:
+				w.Write([]byte(`{"status": "RUNNING"}`))
+			case failed[id]:
+				w.Write([]byte(`{"status": "FAILED", "message": "syntax error"}`))
+			default:
+				w.Write([]byte(`{"status": "FINISHED"}`))
+			}
+		case strings.HasSuffix(path, "/result/"):
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			if offset > len(rows) {
+				offset = len(rows)
+			}
+			end := offset + limit
+			if end > len(rows) {
+				end = len(rows)
+			}
+			if r.Header.Get("accept") != "text/csv" {
+				for _, row := range rows[offset:end] {
+					fields := strings.Split(row, ",")
+					fmt.Fprintf(w, "{\"ITNO\":%q,\"ITDS\":%q}\n", fields[0], fields[1])
+				}
+				return
+			}
+			w.Write([]byte("ITNO,ITDS\n" + strings.Join(rows[offset:end], "\n")))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestRunDataLakeQuery(t *testing.T) {
+	gateway := compassGateway(t)
+	defer func(saved *dataLakeSummary) { report.DataLake = saved }(report.DataLake)
+	dir := t.TempDir()
+
+	tests := []struct {
+		output   string
+		pageSize string
+		pages    int
+		want     string
+	}{
+		{output: "items.csv", pageSize: "2", pages: 2, want: "ITNO,ITDS\nA1,Bolt\nA2,Nut\nA3,Washer\n"},
+		{output: "items.jsonl", pageSize: "3", pages: 2, want: "{\"ITNO\":\"A1\",\"ITDS\":\"Bolt\"}\n{\"ITNO\":\"A2\",\"ITDS\":\"Nut\"}\n{\"ITNO\":\"A3\",\"ITDS\":\"Washer\"}\n"},
+	}
+	for _, tt := range tests {
+		output := filepath.Join(dir, tt.output)
+		err := runDataLakeQuery([]string{gateway.ionAPIFile, "SELECT ITNO, ITDS FROM MITMAS", "--output", output, "--page-size", tt.pageSize, "--poll", "1ms"})
+		if err != nil {
+			t.Fatalf("runDataLakeQuery(%s) failed: %v", tt.output, err)
+		}
+		data, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s =\n%s\nwant\n%s", tt.output, data, tt.want)
+		}
+		if summary := report.DataLake; summary.Rows != 3 || summary.Pages != tt.pages || summary.Status != "FINISHED" {
+			t.Errorf("%s summary = %+v", tt.output, summary)
+		}
+	}
+
+	err := runDataLakeQuery([]string{gateway.ionAPIFile, "SELECT FAIL", "--output", filepath.Join(dir, "failed.csv"), "--poll", "1ms"})
+	if err == nil || !strings.Contains(err.Error(), "failed: syntax error") {
+		t.Errorf("runDataLakeQuery() of a failing query error = %v", err)
+	}
+	if report.DataLake.Status != "FAILED" {
+		t.Errorf("status of a failing query = %q", report.DataLake.Status)
+	}
+
+	err = runDataLakeQuery([]string{gateway.ionAPIFile, "SELECT 1", "--page-size", "0"})
+	if err == nil || !strings.Contains(err.Error(), "--page-size must be between") {
+		t.Errorf("runDataLakeQuery() with --page-size 0 error = %v", err)
+	}
+}
+
+func TestRunDataLakeCheck(t *testing.T) {
+	gateway := newTestGateway(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ACME_TST"+dataCatalogPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"objects": [{"name": "M3_MITMAS"}, {"objectName": "M3_OOHEAD"}]}`))
+	}))
+	defer func(saved *dataLakeSummary) { report.DataLake = saved }(report.DataLake)
+
+	if err := runDataLakeCheck([]string{gateway.ionAPIFile}); err != nil {
+		t.Fatalf("runDataLakeCheck() failed: %v", err)
+	}
+	if objects := report.DataLake.Objects; len(objects) != 2 || objects[0] != "M3_MITMAS" || objects[1] != "M3_OOHEAD" {
+		t.Errorf("objects = %q", objects)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// erpProductFlags are the flags of the connection test that select and configure the ERP product check
+var erpProductFlags = []string{"--product", "--csi-config", "--csi-ido", "--csi-properties", "--csi-record-cap", "--ln-service", "--ln-entity", "--ln-company"}
+
+// erpProductOrder is the order in which products are probed by --product auto
+var erpProductOrder = []string{"m3", "csi", "ln"}
+
+// erpProduct is an Infor ERP reachable through the ION API Gateway
+type erpProduct interface {
+	// name returns the display name of the product
+	name() string
+	// probePath returns a cheap read-only gateway path used to detect the product
+	probePath() string
+	// check calls the product API and prints the result
+	check(client *ionClient) error
+}
+
+// erpCheckResult is the outcome of the ERP product check, added to the report
+type erpCheckResult struct {
+	Product   string         `json:"product"`
+	Detected  bool           `json:"auto_detected,omitempty"`
+	Detection map[string]int `json:"detection,omitempty"`
+	Status    string         `json:"status"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// erpProductFromArgs returns the product selected with --product, configured with its flags
+func erpProductFromArgs(product string, cli cliArgs) (erpProduct, error) {
+	switch strings.ToLower(product) {
+	case "m3":
+		return m3Product{}, nil
+	case "csi", "syteline":
+		recordCap, err := cli.intValue("--csi-record-cap", 1)
+		if err != nil {
+			return nil, err
+		}
+		return csiProduct{
+			config:     cli.value("--csi-config", ""),
+			ido:        cli.value("--csi-ido", "UserNames"),
+			properties: cli.value("--csi-properties", "Username,UserDesc"),
+			recordCap:  recordCap,
+		}, nil
+	case "ln":
+		return lnProduct{
+			service: cli.value("--ln-service", "tdapi.slsSalesOrder"),
+			entity:  cli.value("--ln-entity", "Orders"),
+			company: cli.value("--ln-company", ""),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown product %q, expected m3, csi, ln or auto", product)
+}
+
+// detectERPProduct probes the products in order and returns the first one whose API suite
+// answers the probe with 2xx. The gateway answers 404 for API suites that are not activated for
+// the tenant and 401 or 403 when the service account may not use them.
+func detectERPProduct(client *ionClient, cli cliArgs, result *erpCheckResult) (erpProduct, error) {
+	result.Detection = map[string]int{}
+	var denied []string
+	for _, name := range erpProductOrder {
+		product, err := erpProductFromArgs(name, cli)
+		if err != nil {
+			return nil, err
+		}
+		req, err := client.newRequest("GET", product.probePath(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, _, err := client.do(req, product.name()+" probe")
+		if err != nil {
+			logger.Printf("⚠️ %s probe failed: %v", product.name(), err)
+			continue
+		}
+		result.Detection[name] = resp.StatusCode
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+			logger.Printf("🔎 %s detected (%s)", product.name(), resp.Status)
+			return product, nil
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			logger.Printf("🔒 %s refused the service account (%s), check its roles for the %s API suite", product.name(), resp.Status, product.name())
+			denied = append(denied, fmt.Sprintf("%s (%d)", product.name(), resp.StatusCode))
+		case resp.StatusCode == http.StatusNotFound:
+			logger.Printf("➖ %s is not available (%s)", product.name(), resp.Status)
+		default:
+			logger.Printf("⚠️ %s probe answered %s, not detected", product.name(), resp.Status)
+		}
+	}
+	if len(denied) > 0 {
+		return nil, fmt.Errorf("no product detected, the service account has no permission for %s", strings.Join(denied, ", "))
+	}
+	return nil, fmt.Errorf("none of M3, CSI or LN answered on this tenant")
+}
+
+// runERPCheck runs the product check of --product, detecting the product for auto
+func runERPCheck(client *ionClient, product string, cli cliArgs) error {
+	result := &erpCheckResult{Product: product}
+	report.ERP = result
+
+	var selected erpProduct
+	var err error
+	if strings.EqualFold(product, "auto") {
+		selected, err = detectERPProduct(client, cli, result)
+		result.Detected = err == nil
+	} else {
+		selected, err = erpProductFromArgs(product, cli)
+	}
+	if err == nil {
+		result.Product = selected.name()
+		log.Printf("Calling %s API...", selected.name())
+		err = selected.check(client)
+	}
+	if err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return err
+	}
+	result.Status = "ok"
+	log.Printf("Successfully called %s API", selected.name())
+	return nil
+}
+
+// m3Product checks M3 with the FPW version of CMS535MI
+type m3Product struct{}
+
+func (m3Product) name() string { return "M3" }
+
+func (m3Product) probePath() string { return "/M3/m3api-rest/v2/execute/CMS535MI/FpwVersion" }
+
+func (m3Product) check(client *ionClient) error {
+	return checkM3API(client.token, client.baseURL, client.tenantID)
+}
+
+// csiProduct checks CloudSuite Industrial (SyteLine) by loading a collection of an IDO
+type csiProduct struct {
+	config     string
+	ido        string
+	properties string
+	recordCap  int
+}
+
+func (csiProduct) name() string { return "CSI" }
+
+func (csiProduct) probePath() string { return csiIDOPath + "/configurations" }
+
+func (p csiProduct) check(client *ionClient) error {
+	config, err := csiConfiguration(client, p.config)
+	if err != nil {
+		return err
+	}
+	columns, records, err := loadIDOCollection(client, config, p.ido, p.properties, "", p.recordCap)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("CSI IDO %s (configuration %s):\n", p.ido, config)
+	return printRecords(os.Stdout, "table", columns, records)
+}
+
+// lnProduct checks LN by reading an entity of an OData service
+type lnProduct struct {
+	service string
+	entity  string
+	company string
+}
+
+// lnODataPath is the gateway path of the LN OData services
+const lnODataPath = "/LN/lnapi/odata/"
+
+func (lnProduct) name() string { return "LN" }
+
+func (p lnProduct) probePath() string { return lnODataPath + p.service + "/$metadata" }
+
+func (p lnProduct) check(client *ionClient) error {
+	req, err := client.newRequest("GET", lnODataPath+p.service+"/"+p.entity+"?$top=1", nil)
+	if err != nil {
+		return err
+	}
+	if p.company != "" {
+		req.Header.Set("X-Infor-LnCompany", p.company)
+	}
+	var result struct {
+		Value []map[string]interface{} `json:"value"`
+	}
+	if err := client.doJSON(req, "LN "+p.service, &result); err != nil {
+		return err
+	}
+	fmt.Printf("LN %s/%s:\n", p.service, p.entity)
+	for _, record := range result.Value {
+		keys := make([]string, 0, len(record))
+		for key := range record {
+			if !strings.HasPrefix(key, "@") {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("  %s: %v\n", key, record[key])
+		}
+	}
+	if len(result.Value) == 0 {
+		fmt.Println("  (no records)")
+	}
+	return nil
+}
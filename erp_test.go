@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// erpGateway answers the product probes with the given status, 404 for products not listed. CSI
+// has configuration ACME_TST_SITE with the UserNames IDO.
+func erpGateway(t *testing.T, probes map[string]int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/ACME_TST")
+		probe := map[string]string{
+			(m3Product{}).probePath():                               "m3",
+			(csiProduct{}).probePath():                              "csi",
+			(lnProduct{service: "tdapi.slsSalesOrder"}).probePath(): "ln",
+		}[path]
+		if status, ok := probes[probe]; ok && status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		switch {
+		case path == csiIDOPath+"/configurations" && probes["csi"] == http.StatusOK:
+			w.Write([]byte(`{"Configurations": [{"Name": "ACME_TST_SITE"}, {"Name": "ACME_TST_OTHER"}]}`))
+		case path == csiIDOPath+"/load/UserNames" && r.Header.Get("X-Infor-MongooseConfig") == "ACME_TST_SITE":
+			w.Write([]byte(`{"Success": true, "Items": [{"Username": "sa", "UserDesc": "Administrator", "_ItemId": "PBT=[UserNames]"}]}`))
+		case path == csiIDOPath+"/invoke/SLItems":
+			var params []string
+			json.NewDecoder(r.Body).Decode(&params)
+			if r.URL.Query().Get("method") != "ItemExists" || len(params) != 1 {
+				w.Write([]byte(`{"Success": false, "Message": "Method not found"}`))
+				return
+			}
+			w.Write([]byte(`{"Success": true, "ReturnValue": "0", "Parameters": ["` + params[0] + `"]}`))
+		case probe != "" && probes[probe] == http.StatusOK:
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDetectERPProduct(t *testing.T) {
+	tests := []struct {
+		name      string
+		probes    map[string]int
+		product   string
+		detection map[string]int
+		wantErr   string
+	}{
+		{name: "M3", probes: map[string]int{"m3": 200}, product: "M3", detection: map[string]int{"m3": 200}},
+		{name: "LN", probes: map[string]int{"csi": 404, "ln": 200}, product: "LN", detection: map[string]int{"m3": 404, "csi": 404, "ln": 200}},
+		{name: "CSI after a denied M3", probes: map[string]int{"m3": 403, "csi": 200}, product: "CSI", detection: map[string]int{"m3": 403, "csi": 200}},
+		{name: "denied", probes: map[string]int{"m3": 401, "csi": 500}, detection: map[string]int{"m3": 401, "csi": 500, "ln": 404},
+			wantErr: "no product detected, the service account has no permission for M3 (401)"},
+		{name: "nothing", detection: map[string]int{"m3": 404, "csi": 404, "ln": 404}, wantErr: "none of M3, CSI or LN answered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := erpGateway(t, tt.probes)
+			client := newIonClient(&IonAPI{IonBaseURL: server.URL, TenantID: "ACME_TST"}, "test-token")
+			result := &erpCheckResult{}
+
+			product, err := detectERPProduct(client, parseArgs(nil), result)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("detectERPProduct() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil || product.name() != tt.product {
+				t.Errorf("detectERPProduct() = %v, %v, want %s", product, err, tt.product)
+			}
+			if !reflect.DeepEqual(result.Detection, tt.detection) {
+				t.Errorf("detection = %v, want %v", result.Detection, tt.detection)
+			}
+		})
+	}
+}
+
+func TestRunERPCheck(t *testing.T) {
+	server := erpGateway(t, map[string]int{"m3": 404, "csi": 200})
+	client := newIonClient(&IonAPI{IonBaseURL: server.URL, TenantID: "ACME_TST"}, "test-token")
+	defer func(saved *erpCheckResult) { report.ERP = saved }(report.ERP)
+
+	if err := runERPCheck(client, "auto", parseArgs(nil, erpProductFlags...)); err != nil {
+		t.Fatalf("runERPCheck(auto) failed: %v", err)
+	}
+	if result := report.ERP; result.Product != "CSI" || !result.Detected || result.Status != "ok" {
+		t.Errorf("ERP result = %+v", result)
+	}
+
+	err := runERPCheck(client, "ln", parseArgs(nil, erpProductFlags...))
+	if err == nil || report.ERP.Status != "failed" || report.ERP.Product != "LN" || report.ERP.Detected {
+		t.Errorf("runERPCheck(ln) = %v, result %+v", err, report.ERP)
+	}
+
+	if err := runERPCheck(client, "sap", parseArgs(nil)); err == nil || !strings.Contains(err.Error(), `unknown product "sap"`) {
+		t.Errorf("runERPCheck(sap) error = %v", err)
+	}
+}
+
+func TestERPProductFromArgs(t *testing.T) {
+	cli := parseArgs([]string{"--csi-ido", "SLItems", "--csi-record-cap", "5", "--ln-company", "100"}, erpProductFlags...)
+	csi, err := erpProductFromArgs("SyteLine", cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (csiProduct{ido: "SLItems", properties: "Username,UserDesc", recordCap: 5}); csi != want {
+		t.Errorf("erpProductFromArgs(SyteLine) = %+v, want %+v", csi, want)
+	}
+	ln, err := erpProductFromArgs("LN", cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (lnProduct{service: "tdapi.slsSalesOrder", entity: "Orders", company: "100"}); ln != want {
+		t.Errorf("erpProductFromArgs(LN) = %+v, want %+v", ln, want)
+	}
+	if _, err := erpProductFromArgs("csi", parseArgs([]string{"--csi-record-cap", "all"}, erpProductFlags...)); err == nil {
+		t.Error("erpProductFromArgs() accepted --csi-record-cap all")
+	}
+}
+
+func TestCSICollectionsAndMethods(t *testing.T) {
+	server := erpGateway(t, map[string]int{"csi": 200})
+	client := newIonClient(&IonAPI{IonBaseURL: server.URL, TenantID: "ACME_TST"}, "test-token")
+
+	config, err := csiConfiguration(client, "")
+	if err != nil || config != "ACME_TST_SITE" {
+		t.Fatalf("csiConfiguration() = %q, %v", config, err)
+	}
+	columns, records, err := loadIDOCollection(client, config, "UserNames", "*", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(columnNames(columns), []string{"UserDesc", "Username"}) || records[0]["Username"] != "sa" {
+		t.Errorf("loadIDOCollection() = %v, %v", columnNames(columns), records)
+	}
+
+	result, err := invokeIDOMethod(client, config, "SLItems", "ItemExists", []string{"A1"})
+	if err != nil || result.ReturnValue != "0" || !reflect.DeepEqual(result.Parameters, []interface{}{"A1"}) {
+		t.Errorf("invokeIDOMethod() = %+v, %v", result, err)
+	}
+	if _, err := invokeIDOMethod(client, config, "SLItems", "Missing", nil); err == nil || err.Error() != "SLItems.Missing failed: Method not found" {
+		t.Errorf("invokeIDOMethod() of a missing method error = %v", err)
+	}
+}
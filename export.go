@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// postmanSchema is the version of the Postman collection format written by export
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// exportRequest is a sample call included in the exported collection and script
+type exportRequest struct {
+	Name string
+	Path string
+}
+
+// exportRequests are the sample calls of the export, relative to the gateway URL and tenant
+var exportRequests = []exportRequest{
+	{Name: "M3 version (CMS535MI/FpwVersion)", Path: m3Product{}.probePath()},
+	{Name: "M3 user data (" + m3UserDataTransactions[0] + ")", Path: "/M3/m3api-rest/v2/execute/" + m3UserDataTransactions[0]},
+	{Name: "IFS current user", Path: identityUserPath},
+}
+
+// postmanVariable is a value of a Postman environment
+type postmanVariable struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+// postmanKeyValue is a key and value of a Postman auth, header or urlencoded body
+type postmanKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type,omitempty"`
+}
+
+// postmanAuth is the authorization of a Postman request or collection
+type postmanAuth struct {
+	Type   string            `json:"type"`
+	Basic  []postmanKeyValue `json:"basic,omitempty"`
+	Bearer []postmanKeyValue `json:"bearer,omitempty"`
+}
+
+// postmanBody is the urlencoded form body of a Postman request
+type postmanBody struct {
+	Mode       string            `json:"mode"`
+	URLEncoded []postmanKeyValue `json:"urlencoded"`
+}
+
+// postmanItem is a request of a Postman collection, with the scripts run after it
+type postmanItem struct {
+	Name    string        `json:"name"`
+	Event   []interface{} `json:"event,omitempty"`
+	Request struct {
+		Auth   *postmanAuth      `json:"auth,omitempty"`
+		Method string            `json:"method"`
+		Header []postmanKeyValue `json:"header"`
+		Body   *postmanBody      `json:"body,omitempty"`
+		URL    string            `json:"url"`
+	} `json:"request"`
+}
+
+// runExport writes a Postman environment and collection and a curl script for an .ionapi file:
+// export <ionapi-file-path> [--output-dir <dir>] [--name <name>] [--placeholders]
+func runExport(args []string) error {
+	cli := parseArgs(args, "--output-dir", "--name")
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: export <ionapi-file-path> [--output-dir <dir>] [--name <name>] [--placeholders]")
+	}
+	applyGlobalFlags(cli)
+
+	ionAPI, err := loadIonAPI(cli.positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to load ionapi file: %v", err)
+	}
+	dir := cli.value("--output-dir", ".")
+	name := cli.value("--name", ionAPI.TenantID)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid --name %q, it is used in the file names and cannot contain path separators, use --output-dir for the directory", name)
+	}
+	placeholders := cli.has("--placeholders")
+
+	// Secrets are written with owner-only permissions unless they are left as placeholders
+	perm := os.FileMode(0600)
+	if placeholders {
+		perm = 0644
+	}
+	files := map[string][]byte{}
+
+	environment, err := json.MarshalIndent(postmanEnvironment(ionAPI, name, placeholders), "", "  ")
+	if err != nil {
+		return err
+	}
+	files[name+".postman_environment.json"] = environment
+
+	collection, err := json.MarshalIndent(postmanCollection(name), "", "  ")
+	if err != nil {
+		return err
+	}
+	files[name+".postman_collection.json"] = collection
+	files[name+".sh"] = []byte(curlScript(ionAPI, placeholders))
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, file := range []string{name + ".postman_environment.json", name + ".postman_collection.json", name + ".sh"} {
+		mode := perm
+		if strings.HasSuffix(file, ".sh") {
+			mode |= 0100
+		}
+		path := filepath.Join(dir, file)
+		if err := ioutil.WriteFile(path, files[file], mode); err != nil {
+			return err
+		}
+		// WriteFile keeps the permissions of an existing file, which may be readable by others
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+		logger.Printf("📝 Written %s", path)
+	}
+	if placeholders {
+		logger.Printf("✅ Secrets left as placeholders: fill them in the Postman environment, or set ION_CLIENT_SECRET, ION_SAAK and ION_SASK for the script")
+	} else {
+		logger.Printf("⚠️ The files contain the client secret and service account keys of %s, do not share or commit them", cli.positional[0])
+	}
+	return nil
+}
+
+// postmanEnvironment returns the environment with the values of the .ionapi file. The secrets are
+// left empty with --placeholders.
+func postmanEnvironment(api *IonAPI, name string, placeholders bool) map[string]interface{} {
+	secret := func(value string) string {
+		if placeholders {
+			return ""
+		}
+		return value
+	}
+	return map[string]interface{}{
+		"name": name,
+		"values": []postmanVariable{
+			{Key: "ionapi_url", Value: strings.TrimSuffix(api.IonBaseURL, "/"), Type: "default", Enabled: true},
+			{Key: "tenant", Value: api.TenantID, Type: "default", Enabled: true},
+			{Key: "token_url", Value: api.GetTokenURL(), Type: "default", Enabled: true},
+			{Key: "client_id", Value: api.ClientID, Type: "default", Enabled: true},
+			{Key: "client_secret", Value: secret(api.ClientSecret), Type: "secret", Enabled: true},
+			{Key: "saak", Value: secret(api.Username), Type: "secret", Enabled: true},
+			{Key: "sask", Value: secret(api.Password), Type: "secret", Enabled: true},
+			{Key: "access_token", Value: "", Type: "secret", Enabled: true},
+		},
+		"_postman_variable_scope": "environment",
+	}
+}
+
+// postmanCollection returns a collection with the token request, which stores the access token in
+// the environment, and the sample calls using it as bearer token
+func postmanCollection(name string) map[string]interface{} {
+	token := postmanItem{Name: "Get access token"}
+	token.Request.Method = "POST"
+	token.Request.URL = "{{token_url}}"
+	token.Request.Auth = &postmanAuth{Type: "basic", Basic: []postmanKeyValue{
+		{Key: "username", Value: "{{client_id}}", Type: "string"},
+		{Key: "password", Value: "{{client_secret}}", Type: "string"},
+	}}
+	token.Request.Header = []postmanKeyValue{{Key: "Content-Type", Value: "application/x-www-form-urlencoded"}}
+	token.Request.Body = &postmanBody{Mode: "urlencoded", URLEncoded: []postmanKeyValue{
+		{Key: "grant_type", Value: "password"},
+		{Key: "username", Value: "{{saak}}"},
+		{Key: "password", Value: "{{sask}}"},
+	}}
+	token.Event = []interface{}{map[string]interface{}{
+		"listen": "test",
+		"script": map[string]interface{}{"type": "text/javascript", "exec": []string{
+			`pm.test("access token obtained", () => pm.response.to.have.status(200));`,
+			`pm.environment.set("access_token", pm.response.json().access_token);`,
+		}},
+	}}
+
+	items := []postmanItem{token}
+	for _, sample := range exportRequests {
+		item := postmanItem{Name: sample.Name}
+		item.Request.Method = "GET"
+		item.Request.URL = "{{ionapi_url}}/{{tenant}}" + sample.Path
+		item.Request.Header = []postmanKeyValue{{Key: "Accept", Value: "application/json"}}
+		items = append(items, item)
+	}
+
+	return map[string]interface{}{
+		"info": map[string]interface{}{"name": name + " ION API", "schema": postmanSchema},
+		"auth": postmanAuth{Type: "bearer", Bearer: []postmanKeyValue{{Key: "token", Value: "{{access_token}}", Type: "string"}}},
+		"item": items,
+	}
+}
+
+// curlScript returns a shell script that obtains a token and runs the sample calls with curl.
+// With placeholders the secrets are read from environment variables.
+func curlScript(api *IonAPI, placeholders bool) string {
+	value := func(variable, secret string) string {
+		if placeholders {
+			return fmt.Sprintf(`"${%s:?set %s}"`, variable, variable)
+		}
+		return shellQuote(secret)
+	}
+
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n")
+	script.WriteString("# Calls the ION API of tenant " + api.TenantID + " with curl, generated by Infor-test export\n")
+	script.WriteString("set -e\n\n")
+	fmt.Fprintf(&script, "ION_URL=%s\n", shellQuote(strings.TrimSuffix(api.IonBaseURL, "/")+"/"+api.TenantID))
+	fmt.Fprintf(&script, "TOKEN_URL=%s\n", shellQuote(api.GetTokenURL()))
+	fmt.Fprintf(&script, "CLIENT_ID=%s\n", shellQuote(api.ClientID))
+	fmt.Fprintf(&script, "CLIENT_SECRET=%s\n", value("ION_CLIENT_SECRET", api.ClientSecret))
+	fmt.Fprintf(&script, "SAAK=%s\n", value("ION_SAAK", api.Username))
+	fmt.Fprintf(&script, "SASK=%s\n\n", value("ION_SASK", api.Password))
+
+	script.WriteString("# Password grant with the client ID and secret as Basic authentication\n")
+	script.WriteString(`TOKEN=$(curl -sS --fail -u "$CLIENT_ID:$CLIENT_SECRET" --data-urlencode grant_type=password \` + "\n")
+	script.WriteString(`  --data-urlencode "username=$SAAK" --data-urlencode "password=$SASK" "$TOKEN_URL" |` + "\n")
+	script.WriteString(`  sed -n 's/.*"access_token" *: *"\([^"]*\)".*/\1/p')` + "\n")
+	script.WriteString(`[ -n "$TOKEN" ] || { echo "No access token obtained" >&2; exit 1; }` + "\n")
+
+	for _, sample := range exportRequests {
+		fmt.Fprintf(&script, "\n# %s\n", sample.Name)
+		fmt.Fprintf(&script, "curl -sS -H \"Authorization: Bearer $TOKEN\" -H 'Accept: application/json' \"$ION_URL%s\"\n", sample.Path)
+		script.WriteString("echo\n")
+	}
+	return script.String()
+}
+
+// shellQuote quotes a value for a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
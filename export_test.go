@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunExport(t *testing.T) {
+	gateway := newTestGateway(t, http.NotFoundHandler())
+	dir := filepath.Join(t.TempDir(), "postman")
+
+	// An existing file readable by others gets owner-only permissions
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "ACME_TST.postman_environment.json"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runExport([]string{gateway.ionAPIFile, "--output-dir", dir}); err != nil {
+		t.Fatalf("runExport() failed: %v", err)
+	}
+	modes := map[string]os.FileMode{"ACME_TST.postman_environment.json": 0600, "ACME_TST.postman_collection.json": 0600, "ACME_TST.sh": 0700}
+	for file, mode := range modes {
+		info, err := os.Stat(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != mode {
+			t.Errorf("%s has mode %v, want %v", file, info.Mode().Perm(), mode)
+		}
+	}
+
+	var environment struct {
+		Values []postmanVariable `json:"values"`
+	}
+	readJSON(t, filepath.Join(dir, "ACME_TST.postman_environment.json"), &environment)
+	values := map[string]string{}
+	for _, variable := range environment.Values {
+		values[variable.Key] = variable.Value
+	}
+	if values["ionapi_url"] != gateway.URL || values["token_url"] != gateway.URL+"/as/token.oauth2" || values["client_secret"] != "secret" || values["sask"] != "sask" {
+		t.Errorf("environment values = %v", values)
+	}
+
+	var collection struct {
+		Item []postmanItem `json:"item"`
+	}
+	readJSON(t, filepath.Join(dir, "ACME_TST.postman_collection.json"), &collection)
+	if len(collection.Item) != len(exportRequests)+1 || collection.Item[0].Request.URL != "{{token_url}}" ||
+		collection.Item[1].Request.URL != "{{ionapi_url}}/{{tenant}}"+exportRequests[0].Path {
+		t.Errorf("collection items = %+v", collection.Item)
+	}
+}
+
+func TestRunExportPlaceholders(t *testing.T) {
+	gateway := newTestGateway(t, http.NotFoundHandler())
+	dir := t.TempDir()
+
+	if err := runExport([]string{gateway.ionAPIFile, "--output-dir", dir, "--name", "acme", "--placeholders"}); err != nil {
+		t.Fatalf("runExport() failed: %v", err)
+	}
+	var environment struct {
+		Values []postmanVariable `json:"values"`
+	}
+	readJSON(t, filepath.Join(dir, "acme.postman_environment.json"), &environment)
+	for _, variable := range environment.Values {
+		if variable.Type == "secret" && variable.Value != "" {
+			t.Errorf("environment has %s = %q with --placeholders", variable.Key, variable.Value)
+		}
+	}
+	script, err := ioutil.ReadFile(filepath.Join(dir, "acme.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"'secret'", "'ACME_TST#saak'", "'sask'"} {
+		if strings.Contains(string(script), secret) {
+			t.Errorf("acme.sh contains %s with --placeholders", secret)
+		}
+	}
+	info, err := os.Stat(filepath.Join(dir, "acme.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0744 {
+		t.Errorf("acme.sh has mode %v, want 0744", info.Mode().Perm())
+	}
+
+	for _, name := range []string{"../acme", `acme\tst`, ".."} {
+		err := runExport([]string{gateway.ionAPIFile, "--output-dir", dir, "--name", name})
+		if err == nil || !strings.Contains(err.Error(), "invalid --name") {
+			t.Errorf("runExport(--name %s) error = %v", name, err)
+		}
+	}
+}
+
+// TestCurlScript runs the exported script against the test gateway
+func TestCurlScript(t *testing.T) {
+	shell, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no shell available")
+	}
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl is not available")
+	}
+	var mu sync.Mutex
+	var calls []string
+	gateway := newTestGateway(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		calls = append(calls, strings.TrimPrefix(r.URL.Path, "/ACME_TST"))
+		mu.Unlock()
+		w.Write([]byte(`{}`))
+	}))
+	ionAPI, err := loadIonAPI(gateway.ionAPIFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ionAPI.ClientSecret = "it's a secret"
+
+	script := filepath.Join(t.TempDir(), "test.sh")
+	if err := ioutil.WriteFile(script, []byte(curlScript(ionAPI, false)), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command(shell, script).CombinedOutput(); err != nil {
+		t.Fatalf("script failed: %v\n%s", err, output)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(calls) != len(exportRequests) || calls[0] != exportRequests[0].Path {
+		t.Errorf("script called %q", calls)
+	}
+
+	// With placeholders the secrets must be set in the environment
+	if err := ioutil.WriteFile(script, []byte(curlScript(ionAPI, true)), 0700); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command(shell, script).CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "set ION_CLIENT_SECRET") {
+		t.Errorf("script without ION_CLIENT_SECRET = %v\n%s", err, output)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"secret":        "'secret'",
+		"it's":          `'it'\''s'`,
+		"$HOME `id` \\": "'$HOME `id` \\'",
+		"":              "''",
+	}
+	for value, want := range tests {
+		if got := shellQuote(value); got != want {
+			t.Errorf("shellQuote(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+// readJSON decodes a JSON file of a test
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
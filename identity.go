@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Gateway paths of the identity check
+const (
+	identityUserPath         = "/IFS/usermgt/v2/users/me"
+	identityApplicationsPath = "/OSPORTAL/admin/v1/user/applications"
+)
+
+// Status of the identity check, from best to worst
+const (
+	identityOK                 = "ok"
+	identityMissingPermissions = "missing permissions"
+	identityTokenRejected      = "token rejected"
+	identityUnreachable        = "unreachable"
+)
+
+// identityResult describes the service account the token belongs to
+type identityResult struct {
+	User         string   `json:"user,omitempty"`
+	DisplayName  string   `json:"display_name,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Roles        []string `json:"security_roles,omitempty"`
+	Applications []string `json:"applications,omitempty"`
+	Status       string   `json:"status"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
+// runWhoami reports the user, security roles and applications of the service account: whoami <ionapi-file-path>
+func runWhoami(args []string) error {
+	cli := parseArgs(args)
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: whoami <ionapi-file-path>")
+	}
+	applyGlobalFlags(cli)
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	identity := checkIdentity(newIonClient(ionAPI, token))
+	printIdentity(identity)
+	finishReport()
+	if identity.Status != identityOK {
+		return fmt.Errorf("identity check failed: %s", identity.Status)
+	}
+	return nil
+}
+
+// checkIdentity calls IFS user management for the current user and the OS portal for the applications
+// of the user. Failures are classified so a token without permissions can be told apart from
+// connectivity problems.
+func checkIdentity(client *ionClient) *identityResult {
+	identity := &identityResult{Status: identityOK}
+	report.Identity = identity
+
+	var user interface{}
+	if err := client.getJSON(identityUserPath, "IFS current user", &user); err != nil {
+		identity.fail(err)
+	} else {
+		identity.parseUser(user)
+	}
+
+	var applications interface{}
+	if err := client.getJSON(identityApplicationsPath, "OS portal applications", &applications); err != nil {
+		identity.fail(err)
+	} else {
+		identity.Applications = jsonNames(applications, "applications", "name", "applicationName", "displayName", "logicalId")
+	}
+
+	switch identity.Status {
+	case identityOK:
+		logger.Printf("✅ Token belongs to %s with %d security role(s) and %d application(s)", identity.User, len(identity.Roles), len(identity.Applications))
+	case identityMissingPermissions:
+		logger.Printf("⚠️ Token works but lacks permissions: %s", strings.Join(identity.Errors, "; "))
+	default:
+		logger.Printf("❌ Identity check failed (%s): %s", identity.Status, strings.Join(identity.Errors, "; "))
+	}
+	return identity
+}
+
+// fail records an error and lowers the status according to its kind
+func (identity *identityResult) fail(err error) {
+	identity.Errors = append(identity.Errors, err.Error())
+	status := identityUnreachable
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		// The gateway answers 403 or 404 for API suites the user has no access to
+		switch {
+		case statusErr.StatusCode == http.StatusUnauthorized:
+			status = identityTokenRejected
+		case statusErr.StatusCode < 500:
+			status = identityMissingPermissions
+		}
+	}
+	ranks := map[string]int{identityOK: 0, identityMissingPermissions: 1, identityTokenRejected: 2, identityUnreachable: 3}
+	if ranks[status] > ranks[identity.Status] {
+		identity.Status = status
+	}
+}
+
+// parseUser reads the user from an IFS response, either the user itself or a response.userlist wrapper
+func (identity *identityResult) parseUser(value interface{}) {
+	user, _ := value.(map[string]interface{})
+	if response, ok := user["response"].(map[string]interface{}); ok {
+		if list, ok := response["userlist"].([]interface{}); ok && len(list) > 0 {
+			user, _ = list[0].(map[string]interface{})
+		}
+	}
+	if user == nil {
+		return
+	}
+
+	identity.User = jsonString(user, "userName", "username", "id", "ifsPersonId")
+	identity.DisplayName = jsonString(user, "displayName", "name")
+	if email := jsonString(user, "email"); email != "" {
+		identity.Email = email
+	} else if emails, ok := user["emails"].([]interface{}); ok && len(emails) > 0 {
+		if first, ok := emails[0].(map[string]interface{}); ok {
+			identity.Email = jsonString(first, "value")
+		}
+	}
+
+	// Security roles are listed as groups of type "Security Role", or as roles
+	if groups, ok := user["groups"].([]interface{}); ok {
+		for _, g := range groups {
+			group, ok := g.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if groupType := jsonString(group, "type"); groupType == "" || strings.Contains(strings.ToLower(groupType), "role") {
+				identity.Roles = append(identity.Roles, jsonString(group, "display", "name", "value"))
+			}
+		}
+	}
+	identity.Roles = append(identity.Roles, jsonNames(user["roles"], "roles", "name", "display", "value")...)
+	sort.Strings(identity.Roles)
+}
+
+// printIdentity prints the identity of the service account
+func printIdentity(identity *identityResult) {
+	fmt.Println()
+	fmt.Printf("User:          %s\n", identity.User)
+	if identity.DisplayName != "" {
+		fmt.Printf("Name:          %s\n", identity.DisplayName)
+	}
+	if identity.Email != "" {
+		fmt.Printf("Email:         %s\n", identity.Email)
+	}
+	fmt.Printf("Security roles (%d):\n", len(identity.Roles))
+	for _, role := range identity.Roles {
+		fmt.Printf("  %s\n", role)
+	}
+	fmt.Printf("Applications (%d):\n", len(identity.Applications))
+	for _, application := range identity.Applications {
+		fmt.Printf("  %s\n", application)
+	}
+}
+
+// jsonString returns the first of the given keys of a JSON object that holds a non-empty value
+func jsonString(object map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		switch value := object[key].(type) {
+		case string:
+			if value != "" {
+				return value
+			}
+		case float64, bool:
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+// jsonNames returns the names in a JSON list of strings or objects. The list may be wrapped
+// in an object under listKey or "response"; names are read from the first of nameKeys.
+func jsonNames(value interface{}, listKey string, nameKeys ...string) []string {
+	if object, ok := value.(map[string]interface{}); ok {
+		for _, key := range []string{listKey, "response", "data", "items"} {
+			if inner, ok := object[key]; ok {
+				return jsonNames(inner, listKey, nameKeys...)
+			}
+		}
+		return nil
+	}
+
+	list, _ := value.([]interface{})
+	var names []string
+	for _, item := range list {
+		switch item := item.(type) {
+		case string:
+			names = append(names, item)
+		case map[string]interface{}:
+			if name := jsonString(item, nameKeys...); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
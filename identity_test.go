@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCheckIdentity(t *testing.T) {
+	scimUser := `{"userName": "ACME_TST#SVC", "displayName": "Service account", "emails": [{"value": "svc@acme.example"}],
+		"groups": [{"display": "M3-User", "type": "Security Role"}, {"display": "Finance", "type": "Distribution Group"}, {"value": "IONAPI-User"}]}`
+	wrappedUser := `{"response": {"userlist": [{"username": "SVC", "name": "Service", "email": "svc@acme.example", "roles": ["Z-Role", "A-Role"]}]}}`
+	applications := `{"applications": [{"name": "M3"}, {"logicalId": "lid://infor.ln.1"}, "Data Lake", {"other": "x"}]}`
+
+	tests := []struct {
+		name         string
+		user         string
+		userStatus   int
+		appsStatus   int
+		want         identityResult
+		wantErrCount int
+	}{
+		{
+			name: "SCIM user",
+			user: scimUser,
+			want: identityResult{User: "ACME_TST#SVC", DisplayName: "Service account", Email: "svc@acme.example",
+				Roles: []string{"IONAPI-User", "M3-User"}, Applications: []string{"M3", "lid://infor.ln.1", "Data Lake"}, Status: identityOK},
+		},
+		{
+			name: "user list",
+			user: wrappedUser,
+			want: identityResult{User: "SVC", DisplayName: "Service", Email: "svc@acme.example",
+				Roles: []string{"A-Role", "Z-Role"}, Applications: []string{"M3", "lid://infor.ln.1", "Data Lake"}, Status: identityOK},
+		},
+		{
+			name: "no access to the portal", user: scimUser, appsStatus: http.StatusForbidden,
+			want: identityResult{User: "ACME_TST#SVC", DisplayName: "Service account", Email: "svc@acme.example",
+				Roles: []string{"IONAPI-User", "M3-User"}, Status: identityMissingPermissions},
+			wantErrCount: 1,
+		},
+		{
+			name: "token rejected", userStatus: http.StatusUnauthorized, appsStatus: http.StatusNotFound,
+			want: identityResult{Status: identityTokenRejected}, wantErrCount: 2,
+		},
+		{
+			name: "gateway error", userStatus: http.StatusBadGateway, appsStatus: http.StatusUnauthorized,
+			want: identityResult{Status: identityUnreachable}, wantErrCount: 2,
+		},
+	}
+	defer func(saved *identityResult) { report.Identity = saved }(report.Identity)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, status := "", 0
+				switch r.URL.Path {
+				case "/ACME_TST" + identityUserPath:
+					body, status = tt.user, tt.userStatus
+				case "/ACME_TST" + identityApplicationsPath:
+					body, status = applications, tt.appsStatus
+				default:
+					status = http.StatusNotFound
+				}
+				if status != 0 {
+					w.WriteHeader(status)
+					return
+				}
+				w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			identity := checkIdentity(newIonClient(&IonAPI{IonBaseURL: server.URL, TenantID: "ACME_TST"}, "test-token"))
+			if len(identity.Errors) != tt.wantErrCount {
+				t.Errorf("errors = %q, want %d", identity.Errors, tt.wantErrCount)
+			}
+			identity.Errors = nil
+			if !reflect.DeepEqual(*identity, tt.want) {
+				got, _ := json.Marshal(identity)
+				want, _ := json.Marshal(tt.want)
+				t.Errorf("checkIdentity() = %s, want %s", got, want)
+			}
+			if report.Identity != identity {
+				t.Error("the identity is not in the report")
+			}
+		})
+	}
+}
+
+func TestJSONString(t *testing.T) {
+	object := map[string]interface{}{"empty": "", "id": 42.0, "active": true, "name": "svc"}
+	tests := []struct {
+		keys []string
+		want string
+	}{
+		{[]string{"empty", "name"}, "svc"},
+		{[]string{"id"}, "42"},
+		{[]string{"active"}, "true"},
+		{[]string{"missing"}, ""},
+	}
+	for _, tt := range tests {
+		if got := jsonString(object, tt.keys...); got != tt.want {
+			t.Errorf("jsonString(%v) = %q, want %q", tt.keys, got, tt.want)
+		}
+	}
+}
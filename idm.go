@@ -0,0 +1,380 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Gateway paths of the Document Management (IDM) API
+const (
+	idmEntitiesPath = "/IDM/api/datamodel/entities"
+	idmItemsPath    = "/IDM/api/items"
+	idmSearchPath   = "/IDM/api/items/search"
+)
+
+// idmCommands maps the subcommands of the idm command to the functions running them
+var idmCommands = map[string]func(args []string) error{
+	"check":    runIDMCheck,
+	"search":   runIDMSearch,
+	"download": runIDMDownload,
+	"upload":   runIDMUpload,
+}
+
+// idmSummary is the outcome of an IDM command, added to the report
+type idmSummary struct {
+	DocumentTypes []string      `json:"document_types,omitempty"`
+	Query         string        `json:"query,omitempty"`
+	Items         int           `json:"items,omitempty"`
+	Download      string        `json:"download,omitempty"`
+	Uploads       []idmTransfer `json:"uploads,omitempty"`
+	UploadMBps    float64       `json:"upload_mb_per_s,omitempty"`
+	DownloadMBps  float64       `json:"download_mb_per_s,omitempty"`
+}
+
+// idmTransfer is a test document uploaded, downloaded and deleted again
+type idmTransfer struct {
+	PID        string  `json:"pid,omitempty"`
+	Bytes      int     `json:"bytes"`
+	UploadMS   float64 `json:"upload_ms"`
+	DownloadMS float64 `json:"download_ms,omitempty"`
+	Verified   bool    `json:"verified"`
+	Deleted    bool    `json:"deleted"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// idmItem is an IDM document with its resources
+type idmItem struct {
+	PID         string `json:"pid"`
+	EntityName  string `json:"entityName"`
+	Filename    string `json:"filename"`
+	DisplayName string `json:"displayName"`
+	Created     string `json:"createdTS"`
+	Changed     string `json:"lastChangedTS"`
+	Resources   struct {
+		Res []struct {
+			Name     string `json:"name"`
+			Size     string `json:"size"`
+			MimeType string `json:"mimetype"`
+			Filename string `json:"filename"`
+		} `json:"res"`
+	} `json:"resrs"`
+}
+
+// runIDM runs an idm subcommand
+func runIDM(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: idm <check|search|download|upload> [arguments], see the usage of Infor-test")
+	}
+	command, ok := idmCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown idm subcommand %q", args[0])
+	}
+	return command(args[1:])
+}
+
+// runIDMCheck verifies access to IDM by listing its document types: idm check <ionapi-file-path>
+func runIDMCheck(args []string) error {
+	cli := parseArgs(args)
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: idm check <ionapi-file-path>")
+	}
+	applyGlobalFlags(cli)
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	var entities struct {
+		Entities struct {
+			Entity []struct {
+				Name string `json:"name"`
+				Desc string `json:"desc"`
+			} `json:"entity"`
+		} `json:"entities"`
+	}
+	if err := newIonClient(ionAPI, token).getJSON(idmEntitiesPath, "IDM document types", &entities); err != nil {
+		return fmt.Errorf("failed to list IDM document types: %v", err)
+	}
+
+	summary := &idmSummary{}
+	report.IDM = summary
+	logger.Printf("✅ IDM is accessible, %d document type(s) available", len(entities.Entities.Entity))
+	for _, entity := range entities.Entities.Entity {
+		summary.DocumentTypes = append(summary.DocumentTypes, entity.Name)
+		fmt.Printf("  %-30s %s\n", entity.Name, entity.Desc)
+	}
+	finishReport()
+	return nil
+}
+
+// runIDMSearch searches items with an IDM XQuery: idm search <ionapi-file-path> <xquery> [--limit <n>] [--offset <n>]
+func runIDMSearch(args []string) error {
+	cli := parseArgs(args, "--limit", "--offset", "--format")
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: idm search <ionapi-file-path> <xquery> [--limit <n>] [--offset <n>] [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	format := cli.value("--format", "table")
+	reserveStdout(format)
+
+	limit, err := cli.intValue("--limit", 50)
+	if err != nil {
+		return err
+	}
+	offset, err := cli.intValue("--offset", 0)
+	if err != nil {
+		return err
+	}
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	query.Set("$query", cli.positional[1])
+	query.Set("$offset", strconv.Itoa(offset))
+	query.Set("$limit", strconv.Itoa(limit))
+	var result struct {
+		Items struct {
+			Item []idmItem `json:"item"`
+		} `json:"items"`
+	}
+	if err := newIonClient(ionAPI, token).getJSON(idmSearchPath+"?"+query.Encode(), "IDM search", &result); err != nil {
+		return fmt.Errorf("IDM search failed: %v", err)
+	}
+	items := result.Items.Item
+	report.IDM = &idmSummary{Query: cli.positional[1], Items: len(items)}
+	logger.Printf("✅ %d item(s) found", len(items))
+
+	columns := []column{{Name: "PID"}, {Name: "DOCUMENT_TYPE"}, {Name: "FILENAME"}, {Name: "MIME_TYPE"}, {Name: "SIZE", Type: "N"}, {Name: "CHANGED"}}
+	records := make([]map[string]string, len(items))
+	for i, item := range items {
+		records[i] = map[string]string{"PID": item.PID, "DOCUMENT_TYPE": item.EntityName, "FILENAME": item.Filename, "CHANGED": item.Changed}
+		if records[i]["FILENAME"] == "" {
+			records[i]["FILENAME"] = item.DisplayName
+		}
+		if len(item.Resources.Res) > 0 {
+			records[i]["MIME_TYPE"] = item.Resources.Res[0].MimeType
+			records[i]["SIZE"] = item.Resources.Res[0].Size
+		}
+	}
+	if err := printRecords(os.Stdout, format, columns, records); err != nil {
+		return err
+	}
+	finishReport()
+	return nil
+}
+
+// runIDMDownload downloads the main resource of an item: idm download <ionapi-file-path> <pid> [--output <file>]
+func runIDMDownload(args []string) error {
+	cli := parseArgs(args, "--output")
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: idm download <ionapi-file-path> <pid> [--output <file>]")
+	}
+	applyGlobalFlags(cli)
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	pid := cli.positional[1]
+	start := time.Now()
+	data, err := downloadIDMResource(newIonClient(ionAPI, token), pid)
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+
+	output := cli.value("--output", "")
+	if output == "" {
+		output = filepath.Base(pid)
+	}
+	if err := ioutil.WriteFile(output, data, 0644); err != nil {
+		return err
+	}
+	report.IDM = &idmSummary{Download: output, DownloadMBps: megabytesPerSecond(len(data), elapsed)}
+	logger.Printf("📝 %d bytes of %s written to %s in %s", len(data), pid, output, roundDuration(elapsed))
+	finishReport()
+	return nil
+}
+
+// runIDMUpload uploads test documents, downloads them again to verify them and deletes them:
+// idm upload <ionapi-file-path> [--entity <document type>] [--file <file>] [--size <bytes>] [--count <n>] [--keep]
+func runIDMUpload(args []string) error {
+	cli := parseArgs(args, "--entity", "--file", "--size", "--count")
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: idm upload <ionapi-file-path> [--entity <document type>] [--file <file>] [--size <bytes>] [--count <n>] [--keep]")
+	}
+	applyGlobalFlags(cli)
+
+	count, err := cli.intValue("--count", 1)
+	if err != nil {
+		return err
+	}
+	if count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	var content []byte
+	filename := fmt.Sprintf("infor-test-%s.bin", time.Now().UTC().Format("20060102T150405"))
+	if file := cli.value("--file", ""); file != "" {
+		if content, err = ioutil.ReadFile(file); err != nil {
+			return err
+		}
+		filename = filepath.Base(file)
+	} else {
+		size, err := cli.intValue("--size", 1024*1024)
+		if err != nil {
+			return err
+		}
+		if size < 1 {
+			return fmt.Errorf("--size must be at least 1")
+		}
+		content = make([]byte, size)
+		if _, err := rand.Read(content); err != nil {
+			return err
+		}
+	}
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newIonClient(ionAPI, token)
+	entity := cli.value("--entity", "MDS_File")
+
+	summary := &idmSummary{}
+	report.IDM = summary
+	var uploaded, downloaded time.Duration
+	var uploadedBytes, downloadedBytes int
+	failed := 0
+	for i := 1; i <= count; i++ {
+		transfer := idmTransfer{Bytes: len(content)}
+		start := time.Now()
+		transfer.PID, err = uploadIDMItem(client, entity, filename, content)
+		transfer.UploadMS = milliseconds(time.Since(start))
+		if err != nil {
+			transfer.Error = err.Error()
+			summary.Uploads = append(summary.Uploads, transfer)
+			logger.Printf("❌ Upload %d failed: %v", i, err)
+			failed++
+			continue
+		}
+		uploaded += time.Since(start)
+		uploadedBytes += len(content)
+		logger.Printf("📤 Upload %d: %s (%d bytes) in %.0fms", i, transfer.PID, len(content), transfer.UploadMS)
+
+		start = time.Now()
+		data, err := downloadIDMResource(client, transfer.PID)
+		transfer.DownloadMS = milliseconds(time.Since(start))
+		switch {
+		case err != nil:
+			transfer.Error = err.Error()
+		case !bytes.Equal(data, content):
+			transfer.Error = fmt.Sprintf("downloaded %d bytes differ from the %d bytes uploaded", len(data), len(content))
+		default:
+			transfer.Verified = true
+			downloaded += time.Since(start)
+			downloadedBytes += len(data)
+		}
+		if !transfer.Verified {
+			logger.Printf("❌ Verifying %s failed: %s", transfer.PID, transfer.Error)
+			failed++
+		}
+
+		if !cli.has("--keep") {
+			if err := deleteIDMItem(client, transfer.PID); err != nil {
+				logger.Printf("⚠️ Failed to delete %s, remove it in IDM: %v", transfer.PID, err)
+			} else {
+				transfer.Deleted = true
+			}
+		}
+		summary.Uploads = append(summary.Uploads, transfer)
+	}
+
+	verified := count - failed
+	summary.UploadMBps = megabytesPerSecond(uploadedBytes, uploaded)
+	summary.DownloadMBps = megabytesPerSecond(downloadedBytes, downloaded)
+	if verified > 0 {
+		logger.Printf("✅ %d of %d document(s) uploaded and verified, upload %.2f MB/s, download %.2f MB/s", verified, count, summary.UploadMBps, summary.DownloadMBps)
+	}
+	finishReport()
+	if failed > 0 {
+		return fmt.Errorf("%d of %d upload(s) failed", failed, count)
+	}
+	return nil
+}
+
+// uploadIDMItem creates an item of the document type with the content as its main resource and returns its PID
+func uploadIDMItem(client *ionClient, entity, filename string, content []byte) (string, error) {
+	item := map[string]interface{}{
+		"item": map[string]interface{}{
+			"entityName": entity,
+			"attrs":      map[string]interface{}{"attr": []interface{}{}},
+			"resrs": map[string]interface{}{"res": []interface{}{
+				map[string]interface{}{"filename": filename, "base64": base64.StdEncoding.EncodeToString(content)},
+			}},
+		},
+	}
+	body, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+	req, err := client.newRequest("POST", idmItemsPath+"?$checkout=false", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var created struct {
+		Item idmItem `json:"item"`
+	}
+	if err := client.doJSON(req, "IDM upload", &created); err != nil {
+		return "", err
+	}
+	if created.Item.PID == "" {
+		return "", fmt.Errorf("IDM did not return the PID of the new item")
+	}
+	return created.Item.PID, nil
+}
+
+// downloadIDMResource downloads the main resource of an item
+func downloadIDMResource(client *ionClient, pid string) ([]byte, error) {
+	req, err := client.newRequest("GET", idmItemsPath+"/"+url.PathEscape(pid)+"/resource", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "*/*")
+	resp, body, err := client.do(req, "IDM download")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, &httpStatusError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+	return body, nil
+}
+
+// deleteIDMItem deletes an item
+func deleteIDMItem(client *ionClient, pid string) error {
+	req, err := client.newRequest("DELETE", idmItemsPath+"/"+url.PathEscape(pid), nil)
+	if err != nil {
+		return err
+	}
+	return client.doJSON(req, "IDM delete", nil)
+}
+
+// megabytesPerSecond returns the throughput of a transfer, 0 when nothing was transferred
+func megabytesPerSecond(bytes int, elapsed time.Duration) float64 {
+	if bytes == 0 || elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / 1e6 / elapsed.Seconds()
+}
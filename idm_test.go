@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// idmStore is a fake IDM that keeps uploaded items in memory
+type idmStore struct {
+	mu    sync.Mutex
+	items map[string][]byte
+	// corrupt makes downloads of the nth uploaded item return other bytes
+	corrupt int
+	count   int
+}
+
+func (s *idmStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/ACME_TST")
+	pid := strings.TrimSuffix(strings.TrimPrefix(path, idmItemsPath+"/"), "/resource")
+	switch {
+	case path == idmEntitiesPath:
+		w.Write([]byte(`{"entities": {"entity": [{"name": "MDS_File", "desc": "File"}, {"name": "M3_Invoice", "desc": "Invoice"}]}}`))
+	case r.Method == "POST" && path == idmItemsPath:
+		var upload struct {
+			Item struct {
+				EntityName string `json:"entityName"`
+				Resources  struct {
+					Res []struct {
+						Base64 string `json:"base64"`
+					} `json:"res"`
+				} `json:"resrs"`
+			} `json:"item"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&upload); err != nil || len(upload.Item.Resources.Res) != 1 {
+			http.Error(w, "invalid item", http.StatusBadRequest)
+			return
+		}
+		content, err := base64.StdEncoding.DecodeString(upload.Item.Resources.Res[0].Base64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.count++
+		if s.count == s.corrupt {
+			content = append(content, '!')
+		}
+		pid := fmt.Sprintf("%s-%d-1-LATEST", upload.Item.EntityName, s.count)
+		s.items[pid] = content
+		fmt.Fprintf(w, `{"item": {"pid": %q}}`, pid)
+	case r.Method == "GET" && strings.HasSuffix(path, "/resource"):
+		content, ok := s.items[pid]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	case r.Method == "DELETE":
+		if _, ok := s.items[pid]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		delete(s.items, pid)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRunIDMUpload(t *testing.T) {
+	store := &idmStore{items: map[string][]byte{}}
+	gateway := newTestGateway(t, store)
+	defer func(saved *idmSummary) { report.IDM = saved }(report.IDM)
+
+	if err := runIDMUpload([]string{gateway.ionAPIFile, "--size", "2048", "--count", "2"}); err != nil {
+		t.Fatalf("runIDMUpload() failed: %v", err)
+	}
+	uploads := report.IDM.Uploads
+	if len(uploads) != 2 {
+		t.Fatalf("report has %d uploads, want 2", len(uploads))
+	}
+	for _, upload := range uploads {
+		if !upload.Verified || !upload.Deleted || upload.Bytes != 2048 || !strings.HasPrefix(upload.PID, "MDS_File-") {
+			t.Errorf("upload = %+v", upload)
+		}
+	}
+	if len(store.items) != 0 {
+		t.Errorf("%d test document(s) left in IDM", len(store.items))
+	}
+
+	// The second upload of the next run comes back with other bytes
+	store.corrupt = store.count + 2
+	file := filepath.Join(t.TempDir(), "invoice.pdf")
+	if err := ioutil.WriteFile(file, []byte("%PDF-1.4 test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := runIDMUpload([]string{gateway.ionAPIFile, "--entity", "M3_Invoice", "--file", file, "--count", "2", "--keep"})
+	if err == nil || err.Error() != "1 of 2 upload(s) failed" {
+		t.Errorf("runIDMUpload() error = %v, want one failed upload", err)
+	}
+	uploads = report.IDM.Uploads
+	if len(uploads) != 2 || !uploads[0].Verified || uploads[1].Verified || !strings.Contains(uploads[1].Error, "differ") {
+		t.Errorf("uploads = %+v", uploads)
+	}
+	if len(store.items) != 2 {
+		t.Errorf("%d document(s) in IDM with --keep, want 2", len(store.items))
+	}
+
+	for _, args := range [][]string{{"--count", "0"}, {"--size", "0"}} {
+		if err := runIDMUpload(append([]string{gateway.ionAPIFile}, args...)); err == nil || !strings.Contains(err.Error(), "must be at least 1") {
+			t.Errorf("runIDMUpload(%v) error = %v", args, err)
+		}
+	}
+}
+
+func TestRunIDMDownload(t *testing.T) {
+	store := &idmStore{items: map[string][]byte{"MDS_File-7-1-LATEST": []byte("document")}}
+	gateway := newTestGateway(t, store)
+	defer func(saved *idmSummary) { report.IDM = saved }(report.IDM)
+	output := filepath.Join(t.TempDir(), "document.txt")
+
+	if err := runIDMDownload([]string{gateway.ionAPIFile, "MDS_File-7-1-LATEST", "--output", output}); err != nil {
+		t.Fatalf("runIDMDownload() failed: %v", err)
+	}
+	if data, err := ioutil.ReadFile(output); err != nil || string(data) != "document" {
+		t.Errorf("downloaded %q, %v", data, err)
+	}
+	if err := runIDMDownload([]string{gateway.ionAPIFile, "MDS_File-8-1-LATEST", "--output", output}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("runIDMDownload() of a missing item error = %v", err)
+	}
+}
+
+func TestRunIDMCheck(t *testing.T) {
+	gateway := newTestGateway(t, &idmStore{})
+	defer func(saved *idmSummary) { report.IDM = saved }(report.IDM)
+
+	if err := runIDMCheck([]string{gateway.ionAPIFile}); err != nil {
+		t.Fatalf("runIDMCheck() failed: %v", err)
+	}
+	if types := report.IDM.DocumentTypes; len(types) != 2 || types[0] != "MDS_File" || types[1] != "M3_Invoice" {
+		t.Errorf("document types = %q", types)
+	}
+}
+
+func TestMegabytesPerSecond(t *testing.T) {
+	if got := megabytesPerSecond(5e6, 2*time.Second); got != 2.5 {
+		t.Errorf("megabytesPerSecond(5 MB, 2s) = %g, want 2.5", got)
+	}
+	if got := megabytesPerSecond(0, time.Second); got != 0 {
+		t.Errorf("megabytesPerSecond(0, 1s) = %g", got)
+	}
+	if got := megabytesPerSecond(100, 0); got != 0 {
+		t.Errorf("megabytesPerSecond(100, 0) = %g", got)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ionClient calls ION API Gateway endpoints of a tenant with a bearer token
+type ionClient struct {
+	baseURL  string
+	tenantID string
+	token    string
+	client   *http.Client
+}
+
+// httpStatusError is returned for responses that are not 2xx, with the body for diagnostics
+type httpStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+// Error returns the method, URL and status of the failed request
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s %s failed with status: %s", e.Method, e.URL, e.Status)
+}
+
+// newIonClient creates a client for the ION API Gateway of the .ionapi file
+func newIonClient(api *IonAPI, token string) *ionClient {
+	return &ionClient{
+		baseURL:  strings.TrimSuffix(api.IonBaseURL, "/"),
+		tenantID: api.TenantID,
+		token:    token,
+		client:   &http.Client{},
+	}
+}
+
+// url resolves a path against the gateway URL and tenant. Paths may include the tenant or
+// be full URLs, so /IFS/usermgt/v2/users/me and /TENANT/IFS/usermgt/v2/users/me are the same.
+// Full URLs must point to the gateway, so the token is never sent to another host.
+func (c *ionClient) url(path string) (string, error) {
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		target, err := url.Parse(path)
+		if err != nil {
+			return "", err
+		}
+		gateway, err := url.Parse(c.baseURL)
+		if err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(target.Scheme, gateway.Scheme) || !strings.EqualFold(target.Host, gateway.Host) {
+			return "", fmt.Errorf("%s is not on the ION API Gateway %s://%s, the token is only sent to the gateway", path, gateway.Scheme, gateway.Host)
+		}
+		return path, nil
+	}
+	path = "/" + strings.TrimPrefix(path, "/")
+	if strings.HasPrefix(path, "/"+c.tenantID+"/") {
+		return c.baseURL + path, nil
+	}
+	return c.baseURL + "/" + c.tenantID + path, nil
+}
+
+// newRequest creates an authenticated request for a gateway path
+func (c *ionClient) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	target, err := c.url(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	req.Header.Set("accept", "application/json")
+	return req, nil
+}
+
+// do sends the request with timing and returns the response with its body, for any status
+func (c *ionClient) do(req *http.Request, label string) (*http.Response, []byte, error) {
+	req, timing := withTiming(req, label)
+	debugPrint("Sending %s request to: %s", req.Method, req.URL)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		timing.fail(err)
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		timing.fail(err)
+		return nil, nil, err
+	}
+	timing.finish()
+	debugPrint("API Response: %s", body)
+	return resp, body, nil
+}
+
+// getJSON sends a GET request to a gateway path and decodes the JSON response into v.
+// Responses that are not 2xx are returned as *httpStatusError.
+func (c *ionClient) getJSON(path, label string, v interface{}) error {
+	req, err := c.newRequest("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	return c.doJSON(req, label, v)
+}
+
+// doJSON sends a request and decodes the JSON response into v, if v is not nil
+func (c *ionClient) doJSON(req *http.Request, label string, v interface{}) error {
+	resp, body, err := c.do(req, label)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &httpStatusError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+	if v == nil || len(body) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse response of %s: %v", label, err)
+	}
+	return nil
+}
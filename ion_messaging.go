@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Gateway paths of the ION messaging and tracking APIs
+const (
+	ionMessagePath  = "/IONSERVICES/api/ion/messaging/service/v3/multipartMessage"
+	ionTrackingPath = "/IONSERVICES/oneviewapi/messages"
+)
+
+// ionCommands maps the subcommands of the ion command to the functions running them
+var ionCommands = map[string]func(args []string) error{
+	"send-bod": runIonSendBOD,
+}
+
+// ionParameterRequest is the ParameterRequest part of a multipart message
+type ionParameterRequest struct {
+	DocumentName  string `json:"documentName"`
+	MessageID     string `json:"messageId"`
+	FromLogicalID string `json:"fromLogicalId"`
+	ToLogicalID   string `json:"toLogicalId"`
+	Encoding      string `json:"encoding"`
+	CharacterSet  string `json:"characterSet"`
+}
+
+// ionMessageSummary is the outcome of sending a BOD, added to the report
+type ionMessageSummary struct {
+	DocumentName  string `json:"document_name"`
+	MessageID     string `json:"message_id"`
+	FromLogicalID string `json:"from_logical_id"`
+	ToLogicalID   string `json:"to_logical_id"`
+	Accepted      bool   `json:"accepted"`
+	TrackingState string `json:"tracking_status,omitempty"`
+}
+
+// runIon runs an ion subcommand
+func runIon(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: ion <subcommand> [arguments], see the usage of Infor-test")
+	}
+	command, ok := ionCommands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown ion subcommand %q", args[0])
+	}
+	return command(args[1:])
+}
+
+// runIonSendBOD sends a BOD from a template to ION through an API connection point:
+// ion send-bod <ionapi-file-path> <template.xml> --connection-point <logical-id> [--var NAME=VALUE ...] [--track]
+func runIonSendBOD(args []string) error {
+	cli := parseArgs(args, "--connection-point", "--to", "--var", "--document", "--track-timeout", "--poll", "--output", "--tracking-path")
+	if len(cli.positional) < 2 || !cli.has("--connection-point") {
+		return fmt.Errorf("usage: ion send-bod <ionapi-file-path> <template.xml> --connection-point <logical-id> [--to <logical-id>] [--var NAME=VALUE ...] [--document <Verb.Noun>] [--track] [--track-timeout <d>] [--tracking-path <path>] [--skip-validation]")
+	}
+	applyGlobalFlags(cli)
+
+	template, err := ioutil.ReadFile(cli.positional[1])
+	if err != nil {
+		return err
+	}
+	// The template is expanded and validated before the token is requested
+	ionAPI, err := loadIonAPI(cli.positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to load ionapi file: %v", err)
+	}
+
+	fromLogicalID := cli.value("--connection-point", "")
+	messageID, err := newUUID()
+	if err != nil {
+		return err
+	}
+	bodID, err := newUUID()
+	if err != nil {
+		return err
+	}
+	vars := map[string]string{
+		"TENANT":     ionAPI.TenantID,
+		"LOGICAL_ID": fromLogicalID,
+		"MESSAGE_ID": messageID,
+		"BOD_ID":     bodID,
+		"TIMESTAMP":  time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, entry := range cli.values("--var") {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid variable %q, expected NAME=VALUE", entry)
+		}
+		vars[name] = value
+	}
+	bod, err := expandBODTemplate(string(template), vars)
+	if err != nil {
+		return err
+	}
+	if !cli.has("--skip-validation") {
+		validation := validateBOD(bod, bodExpectations{TenantID: ionAPI.TenantID, LogicalID: fromLogicalID})
+		printBODIssues(cli.positional[1], validation.Issues)
+		if !validation.Valid {
+			return fmt.Errorf("the expanded BOD is not valid, fix the template or use --skip-validation")
+		}
+	}
+	if output := cli.value("--output", ""); output != "" {
+		if err := ioutil.WriteFile(output, []byte(bod), 0644); err != nil {
+			return err
+		}
+		logger.Printf("📝 Expanded BOD written to %s", output)
+	}
+
+	documentName := cli.value("--document", "")
+	if documentName == "" {
+		root, err := bodRootName(bod)
+		if err != nil {
+			return err
+		}
+		verb, noun, ok := bodVerbNoun(root)
+		if !ok {
+			return fmt.Errorf("cannot derive the document name from root element %s, set --document <Verb.Noun>", root)
+		}
+		documentName = verb + "." + noun
+	}
+
+	token, err := authenticateIonAPI(ionAPI)
+	if err != nil {
+		return err
+	}
+	client := newIonClient(ionAPI, token)
+
+	params := ionParameterRequest{
+		DocumentName:  documentName,
+		MessageID:     messageID,
+		FromLogicalID: fromLogicalID,
+		ToLogicalID:   cli.value("--to", "lid://default"),
+		Encoding:      "NONE",
+		CharacterSet:  "UTF-8",
+	}
+	summary := &ionMessageSummary{DocumentName: params.DocumentName, MessageID: messageID, FromLogicalID: params.FromLogicalID, ToLogicalID: params.ToLogicalID}
+	report.IonMessage = summary
+
+	if err := sendIonMessage(client, params, []byte(bod)); err != nil {
+		return err
+	}
+	summary.Accepted = true
+	logger.Printf("✅ %s accepted by ION with message ID %s (from %s to %s)", documentName, messageID, params.FromLogicalID, params.ToLogicalID)
+
+	if cli.has("--track") {
+		timeout, err := time.ParseDuration(cli.value("--track-timeout", "2m"))
+		if err != nil {
+			return fmt.Errorf("invalid value for --track-timeout: %v", err)
+		}
+		poll, err := time.ParseDuration(cli.value("--poll", "5s"))
+		if err != nil {
+			return fmt.Errorf("invalid value for --poll: %v", err)
+		}
+		status, err := trackIonMessage(client, cli.value("--tracking-path", ionTrackingPath), messageID, timeout, poll)
+		summary.TrackingState = status
+		if err != nil {
+			finishReport()
+			return err
+		}
+	}
+	finishReport()
+	return nil
+}
+
+// sendIonMessage posts the BOD as a multipart message with its ParameterRequest
+func sendIonMessage(client *ionClient, params ionParameterRequest, payload []byte) error {
+	parameters, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="ParameterRequest"; filename="ParameterRequest.json"`},
+		"Content-Type":        {"application/json"},
+	})
+	if err != nil {
+		return err
+	}
+	part.Write(parameters)
+	part, err = writer.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="MessagePayload"; filename="MessagePayload.xml"`},
+		"Content-Type":        {"application/octet-stream"},
+	})
+	if err != nil {
+		return err
+	}
+	part.Write(payload)
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	req, err := client.newRequest("POST", ionMessagePath, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if err := client.doJSON(req, "ION send "+params.DocumentName, nil); err != nil {
+		return fmt.Errorf("ION did not accept the message: %v", err)
+	}
+	return nil
+}
+
+// trackIonMessage polls the OneView tracking path until the message has a final status or the timeout expires
+func trackIonMessage(client *ionClient, trackingPath, messageID string, timeout, poll time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	status := ""
+	for time.Now().Before(deadline) {
+		time.Sleep(poll)
+		var tracking interface{}
+		err := client.getJSON(trackingPath+"?messageId="+url.QueryEscape(messageID), "ION tracking", &tracking)
+		if err != nil {
+			var statusErr *httpStatusError
+			if errors.As(err, &statusErr) && statusErr.StatusCode == 404 {
+				logger.Printf("⏳ Message %s is not tracked yet", messageID)
+				continue
+			}
+			return status, fmt.Errorf("failed to track message %s: %v", messageID, err)
+		}
+
+		if current := findJSONString(tracking, "status"); current != status {
+			status = current
+			logger.Printf("📍 Message %s is %s", messageID, status)
+		}
+		switch strings.ToUpper(status) {
+		case "DELIVERED", "PROCESSED", "COMPLETED", "SUCCESS":
+			logger.Printf("✅ Message %s was delivered", messageID)
+			return status, nil
+		case "ERROR", "FAILED", "REJECTED":
+			return status, fmt.Errorf("message %s ended with status %s", messageID, status)
+		}
+	}
+	return status, fmt.Errorf("no final tracking status for message %s after %s", messageID, timeout)
+}
+
+// findJSONString returns the first string value of key found in a decoded JSON value, searching depth first
+func findJSONString(value interface{}, key string) string {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if s, ok := value[key].(string); ok && s != "" {
+			return s
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if s := findJSONString(value[k], key); s != "" {
+				return s
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if s := findJSONString(item, key); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
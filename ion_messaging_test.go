@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// itemTemplate is a BOD template with a ${ITEM} placeholder in text and in an attribute
+const itemTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<SyncItemMaster releaseID="9.2">
+  <ApplicationArea>
+    <Sender><LogicalID>${LOGICAL_ID}</LogicalID></Sender>
+    <CreationDateTime>${TIMESTAMP}</CreationDateTime>
+    <BODID>${BOD_ID}</BODID>
+  </ApplicationArea>
+  <DataArea>
+    <Sync><TenantID>${TENANT}</TenantID></Sync>
+    <ItemMaster><ItemMasterHeader><ItemID><ID note="${ITEM}">${ITEM}</ID></ItemID></ItemMasterHeader></ItemMaster>
+  </DataArea>
+</SyncItemMaster>`
+
+func TestExpandBODTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		vars     map[string]string
+		want     string
+		wantErr  string
+	}{
+		{name: "text", template: "<ID>${ITEM}</ID>", vars: map[string]string{"ITEM": "A100"}, want: "<ID>A100</ID>"},
+		{name: "escaped text", template: "<ID>${ITEM}</ID>", vars: map[string]string{"ITEM": "Salt & <Pepper>"}, want: "<ID>Salt &amp; &lt;Pepper&gt;</ID>"},
+		{name: "escaped attribute", template: `<ID note="${NOTE}"/>`, vars: map[string]string{"NOTE": `6" pipe`}, want: `<ID note="6&#34; pipe"/>`},
+		{name: "repeated", template: "${A}-${A}", vars: map[string]string{"A": "x"}, want: "x-x"},
+		{name: "empty value", template: "<ID>${ITEM}</ID>", vars: map[string]string{"ITEM": ""}, want: "<ID></ID>"},
+		{name: "missing", template: "${B}${A}${B}", vars: map[string]string{}, wantErr: "no value for template variable(s) A, B"},
+		{name: "not a placeholder", template: "$ITEM {ITEM} ${}", vars: map[string]string{"ITEM": "x"}, want: "$ITEM {ITEM} ${}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandBODTemplate(tt.template, tt.vars)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expandBODTemplate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expandBODTemplate() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBODVerbNoun(t *testing.T) {
+	tests := []struct {
+		name, verb, noun string
+		ok               bool
+	}{
+		{"SyncItemMaster", "Sync", "ItemMaster", true},
+		{"ProcessPurchaseOrder", "Process", "PurchaseOrder", true},
+		{"AcknowledgeSalesOrder", "Acknowledge", "SalesOrder", true},
+		{"Sync", "", "", false},
+		{"ItemMaster", "", "", false},
+	}
+	for _, tt := range tests {
+		verb, noun, ok := bodVerbNoun(tt.name)
+		if verb != tt.verb || noun != tt.noun || ok != tt.ok {
+			t.Errorf("bodVerbNoun(%q) = %q, %q, %v", tt.name, verb, noun, ok)
+		}
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id, err := newUUID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !uuid.MatchString(id) || seen[id] {
+			t.Fatalf("newUUID() = %q, want a new version 4 UUID", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRunIonSendBOD(t *testing.T) {
+	var parameters ionParameterRequest
+	var payload string
+	var tracked int32
+	gateway := newTestGateway(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ACME_TST" + ionMessagePath:
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for name, files := range r.MultipartForm.File {
+				file, _ := files[0].Open()
+				data, _ := ioutil.ReadAll(file)
+				file.Close()
+				if name == "ParameterRequest" {
+					json.Unmarshal(data, &parameters)
+				} else {
+					payload = string(data)
+				}
+			}
+			w.Write([]byte(`{"status": "accepted"}`))
+		case "/ACME_TST" + ionTrackingPath:
+			if atomic.AddInt32(&tracked, 1) == 1 {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(`{"messages": [{"messageId": "` + r.URL.Query().Get("messageId") + `", "status": "DELIVERED"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	template := filepath.Join(t.TempDir(), "item.xml")
+	if err := ioutil.WriteFile(template, []byte(itemTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runIonSendBOD([]string{gateway.ionAPIFile, template, "--connection-point", "lid://infor.ims.test",
+		"--var", "ITEM=Nuts & <Bolts>", "--track", "--poll", "1ms", "--track-timeout", "5s"})
+	if err != nil {
+		t.Fatalf("runIonSendBOD() failed: %v", err)
+	}
+	if parameters.DocumentName != "Sync.ItemMaster" || parameters.FromLogicalID != "lid://infor.ims.test" || parameters.ToLogicalID != "lid://default" {
+		t.Errorf("ParameterRequest = %+v", parameters)
+	}
+	if validation := validateBOD(payload, bodExpectations{TenantID: "ACME_TST"}); !validation.Valid {
+		t.Errorf("the sent BOD is not valid: %+v\n%s", validation.Issues, payload)
+	}
+	if !strings.Contains(payload, `<ID note="Nuts &amp; &lt;Bolts&gt;">Nuts &amp; &lt;Bolts&gt;</ID>`) {
+		t.Errorf("the --var value is not escaped in the BOD:\n%s", payload)
+	}
+	if report.IonMessage == nil || report.IonMessage.TrackingState != "DELIVERED" {
+		t.Errorf("report message = %+v, want it delivered", report.IonMessage)
+	}
+}
+
+func TestRunIonSendBODValidatesBeforeAuthenticating(t *testing.T) {
+	gateway := newTestGateway(t, http.NotFoundHandler())
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		template string
+		args     []string
+		wantErr  string
+	}{
+		{name: "missing variable", template: itemTemplate, wantErr: "no value for template variable(s) ITEM"},
+		{name: "not well-formed", template: "<SyncItemMaster>", args: []string{"--var", "ITEM=x"}, wantErr: "the expanded BOD is not valid"},
+		{name: "other tenant", template: strings.Replace(itemTemplate, "${TENANT}", "ACME_PRD", 1), args: []string{"--var", "ITEM=x"}, wantErr: "the expanded BOD is not valid"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := filepath.Join(dir, strings.Repeat("t", i+1)+".xml")
+			if err := ioutil.WriteFile(template, []byte(tt.template), 0644); err != nil {
+				t.Fatal(err)
+			}
+			args := append([]string{gateway.ionAPIFile, template, "--connection-point", "lid://infor.ims.test"}, tt.args...)
+			err := runIonSendBOD(args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runIonSendBOD() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+	if tokens := atomic.LoadInt32(&gateway.tokens); tokens != 0 {
+		t.Errorf("%d token request(s) for templates that fail validation, want none", tokens)
+	}
+}
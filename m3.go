@@ -2,38 +2,98 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"os"
+	"sort"
+
+	"inforconnectiontest/m3api"
 )
 
-// checkM3API calls the /M3/m3api-rest/application.wadl endpoint
-func checkM3API(token string, baseURL string, tenantID string) error {
-	apiEndpoint := fmt.Sprintf("%s/%s/M3/m3api-rest/v2/execute/CMS535MI/FpwVersion?dateformat=YMD8&excludeempty=false&righttrim=true&format=PRETTY&extendedresult=false", baseURL, tenantID)
+// The M3 client lives in the m3api package, so the code generated by m3 codegen can import it
+type (
+	m3Client       = m3api.Client
+	m3Options      = m3api.Options
+	m3Response     = m3api.Response
+	m3Result       = m3api.Result
+	m3Error        = m3api.Error
+	m3Transaction  = m3api.Transaction
+	m3MultiRequest = m3api.MultiRequest
+)
 
-	req, err := http.NewRequest("GET", apiEndpoint, nil)
-	if err != nil {
-		return err
-	}
+// defaultM3Options are the options used by checkM3API
+var defaultM3Options = m3api.DefaultOptions
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	req.Header.Set("accept", "application/json")
+// parseM3Response parses the body of an execute response
+func parseM3Response(body []byte) (*m3Response, error) {
+	return m3api.ParseResponse(body)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
+// newM3Client creates a client for the M3 REST API of the tenant that times its requests, prints
+// the responses in debug mode and applies the write policy and audit log to write transactions
+func newM3Client(token string, baseURL string, tenantID string) *m3Client {
+	client := m3api.NewClient(token, baseURL, tenantID)
+	client.Trace = func(req *http.Request, label string) (*http.Request, func(error)) {
+		req, timing := withTiming(req, label)
+		return req, func(err error) {
+			if err != nil {
+				timing.fail(err)
+			} else {
+				timing.finish()
+			}
+		}
+	}
+	client.Debug = debugPrint
+	client.Guard = func(program, transaction string, record map[string]string) (string, error) {
+		return guardM3Write(tenantID, program, transaction, record)
 	}
-	defer resp.Body.Close()
+	client.Audit = func(decision, program, transaction string, record map[string]string, err error) {
+		auditM3Write(tenantID, decision, program, transaction, record, err)
+	}
+	return client
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API request failed with status: %s", resp.Status)
+// m3Columns returns the output fields of the result, in metadata order, followed by any
+// field found in the records but missing from the metadata
+func m3Columns(r *m3Result) []column {
+	seen := map[string]bool{}
+	var columns []column
+	for _, field := range r.Metadata.Fields {
+		columns = append(columns, column(field))
+		seen[field.Name] = true
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	var extra []string
+	for _, record := range r.Records {
+		for name := range record {
+			if !seen[name] {
+				seen[name] = true
+				extra = append(extra, name)
+			}
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		columns = append(columns, column{Name: name})
+	}
+	return columns
+}
+
+// checkM3API calls CMS535MI/FpwVersion to check the M3 API is reachable with the token, and reads
+// the user, company and division of the service account into the M3 environment of the report
+func checkM3API(token string, baseURL string, tenantID string) error {
+	env, err := collectM3Environment(newM3Client(token, baseURL, tenantID))
 	if err != nil {
 		return err
 	}
+	report.M3Environment = env
+	for _, message := range env.Errors {
+		logger.Printf("⚠️ M3 user data not available: %s", message)
+	}
 
-	fmt.Printf("M3 API Response: %s\n", body)
-	return nil
+	fmt.Println("M3 API Response:")
+	version := &m3Result{}
+	if record, ok := env.Raw["CMS535MI/FpwVersion"]; ok {
+		version.Records = []map[string]string{record}
+	}
+	return printRecords(os.Stdout, "table", m3Columns(version), version.Records)
 }
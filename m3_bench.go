@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// benchSummary is the outcome of a benchmark, added to the report
+type benchSummary struct {
+	Transaction string             `json:"transaction"`
+	Concurrency int                `json:"concurrency"`
+	Rate        float64            `json:"rate_limit,omitempty"`
+	DurationMS  float64            `json:"duration_ms"`
+	Requests    int                `json:"requests"`
+	Succeeded   int                `json:"succeeded"`
+	Throughput  float64            `json:"requests_per_second"`
+	ErrorRate   float64            `json:"error_rate"`
+	Errors      map[string]int     `json:"errors,omitempty"`
+	LatencyMS   map[string]float64 `json:"latency_ms"`
+}
+
+// benchSample is the outcome of a single request
+type benchSample struct {
+	latency time.Duration
+	errType string
+}
+
+// runM3Bench executes a transaction repeatedly to measure throughput and latency:
+// m3 bench <ionapi-file-path> <PROGRAM>/<TRANSACTION> [--concurrency <n>] [--duration <d>] [--rate <n/s>]
+func runM3Bench(args []string) error {
+	cli := parseArgs(args, append(m3ValueFlags, "--concurrency", "--duration", "--rate")...)
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: m3 bench <ionapi-file-path> <PROGRAM>/<TRANSACTION> [--param KEY=VALUE ...] [--concurrency <n>] [--duration <d>] [--rate <requests/s>]")
+	}
+	applyGlobalFlags(cli)
+
+	program, transaction, err := parseTransaction(cli.positional[1])
+	if err != nil {
+		return err
+	}
+	params, err := parseParams(cli.values("--param"))
+	if err != nil {
+		return err
+	}
+	opts, err := m3OptionsFromArgs(cli)
+	if err != nil {
+		return err
+	}
+	concurrency, err := cli.intValue("--concurrency", 10)
+	if err != nil {
+		return err
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1")
+	}
+	duration, err := time.ParseDuration(cli.value("--duration", "30s"))
+	if err != nil {
+		return fmt.Errorf("invalid value for --duration: %v", err)
+	}
+	rate, interval, err := benchRate(cli.value("--rate", "0"))
+	if err != nil {
+		return err
+	}
+	// Repeating a write transaction would create or change data with every request
+	if policy.isWrite(transaction) {
+		return fmt.Errorf("%s/%s changes data, m3 bench only runs read transactions", program, transaction)
+	}
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newM3Client(token, ionAPI.IonBaseURL, ionAPI.TenantID)
+
+	// All workers share one token and one connection pool, as an integration would
+	pool := &http.Client{
+		Timeout: 60 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			DialContext:         dialContext,
+			MaxIdleConns:        concurrency,
+			MaxIdleConnsPerHost: concurrency,
+			ForceAttemptHTTP2:   true,
+		},
+	}
+	requestURL := client.ExecuteURL(program, transaction, params, opts)
+
+	logger.Printf("🏁 Benchmarking %s/%s for %s with %d worker(s)%s", program, transaction, duration, concurrency, rateText(rate))
+	stop := make(chan struct{})
+	timer := time.AfterFunc(duration, func() { close(stop) })
+	defer timer.Stop()
+	var ticks <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var mu sync.Mutex
+	var samples []benchSample
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if ticks != nil {
+					select {
+					case <-ticks:
+					case <-stop:
+						return
+					}
+				}
+				sample := benchRequest(pool, requestURL, token)
+				mu.Lock()
+				samples = append(samples, sample)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	// Progress every 5 seconds
+	done := make(chan struct{})
+	go func() {
+		progress := time.NewTicker(5 * time.Second)
+		defer progress.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-progress.C:
+				mu.Lock()
+				n := len(samples)
+				mu.Unlock()
+				logger.Printf("⏳ %d request(s), %.1f/s", n, float64(n)/time.Since(start).Seconds())
+			}
+		}
+	}()
+	wg.Wait()
+	close(done)
+	elapsed := time.Since(start)
+
+	summary := summarizeBench(samples, elapsed)
+	summary.Transaction = program + "/" + transaction
+	summary.Concurrency = concurrency
+	summary.Rate = rate
+	report.Bench = &summary
+
+	printBenchSummary(summary)
+	finishReport()
+	return nil
+}
+
+// benchRequest sends one execute request and classifies its outcome
+func benchRequest(pool *http.Client, requestURL, token string) benchSample {
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return benchSample{errType: "request"}
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("accept", "application/json")
+
+	start := time.Now()
+	resp, err := pool.Do(req)
+	if err != nil {
+		sample := benchSample{latency: time.Since(start), errType: "network"}
+		if strings.Contains(err.Error(), "Timeout") || strings.Contains(err.Error(), "deadline") {
+			sample.errType = "timeout"
+		}
+		return sample
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	sample := benchSample{latency: time.Since(start)}
+
+	switch {
+	case err != nil:
+		sample.errType = "network"
+	case resp.StatusCode == http.StatusTooManyRequests:
+		sample.errType = "HTTP 429 throttled"
+	case resp.StatusCode != http.StatusOK:
+		sample.errType = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	default:
+		response, err := parseM3Response(body)
+		if err != nil {
+			sample.errType = "invalid response"
+		} else if len(response.Results) == 0 {
+			sample.errType = "no result"
+		} else if response.Results[0].Failed() {
+			sample.errType = strings.TrimSpace("M3 error " + response.Results[0].ErrorCode)
+		}
+	}
+	return sample
+}
+
+// summarizeBench computes throughput, errors by type and latency percentiles of the samples
+func summarizeBench(samples []benchSample, elapsed time.Duration) benchSummary {
+	summary := benchSummary{
+		DurationMS: milliseconds(elapsed),
+		Requests:   len(samples),
+		Errors:     map[string]int{},
+		LatencyMS:  map[string]float64{},
+	}
+	if len(samples) == 0 {
+		return summary
+	}
+
+	latencies := make([]time.Duration, len(samples))
+	var total time.Duration
+	for i, sample := range samples {
+		latencies[i] = sample.latency
+		total += sample.latency
+		if sample.errType == "" {
+			summary.Succeeded++
+		} else {
+			summary.Errors[sample.errType]++
+		}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	summary.Throughput = float64(len(samples)) / elapsed.Seconds()
+	summary.ErrorRate = float64(len(samples)-summary.Succeeded) / float64(len(samples))
+	summary.LatencyMS["min"] = milliseconds(latencies[0])
+	summary.LatencyMS["mean"] = milliseconds(total / time.Duration(len(latencies)))
+	for _, p := range []int{50, 90, 95, 99} {
+		summary.LatencyMS[fmt.Sprintf("p%d", p)] = milliseconds(percentile(latencies, p))
+	}
+	summary.LatencyMS["max"] = milliseconds(latencies[len(latencies)-1])
+	return summary
+}
+
+// percentile returns the p-th percentile of sorted latencies, using the nearest rank
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// printBenchSummary prints the throughput, errors and latency percentiles of a benchmark
+func printBenchSummary(s benchSummary) {
+	out := logger.Writer()
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Benchmark of %s, %d worker(s)%s:\n", s.Transaction, s.Concurrency, rateText(s.Rate))
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Requests\t%d in %s\n", s.Requests, roundDuration(time.Duration(s.DurationMS*float64(time.Millisecond))))
+	fmt.Fprintf(w, "Throughput\t%.1f requests/s\n", s.Throughput)
+	fmt.Fprintf(w, "Succeeded\t%d\n", s.Succeeded)
+	fmt.Fprintf(w, "Error rate\t%.2f%%\n", s.ErrorRate*100)
+	errTypes := make([]string, 0, len(s.Errors))
+	for errType := range s.Errors {
+		errTypes = append(errTypes, errType)
+	}
+	sort.Strings(errTypes)
+	for _, errType := range errTypes {
+		fmt.Fprintf(w, "  %s\t%d\n", errType, s.Errors[errType])
+	}
+	for _, name := range []string{"min", "mean", "p50", "p90", "p95", "p99", "max"} {
+		if value, ok := s.LatencyMS[name]; ok {
+			fmt.Fprintf(w, "Latency %s\t%.1f ms\n", name, value)
+		}
+	}
+	w.Flush()
+	if s.Errors["HTTP 429 throttled"] > 0 {
+		logger.Printf("⚠️ The gateway throttled %d request(s) with HTTP 429", s.Errors["HTTP 429 throttled"])
+	}
+}
+
+// benchRate parses --rate and returns the interval between requests, 0 for no limit. The interval
+// must fit a time.Duration and be at least 1ns, which limits the rate to 1e9 requests/s.
+func benchRate(value string) (float64, time.Duration, error) {
+	var rate float64
+	if _, err := fmt.Sscan(value, &rate); err != nil || !(rate >= 0) {
+		return 0, 0, fmt.Errorf("invalid value for --rate: %s, expected 0 (no limit) or 1.1e-10 to 1e9 requests/s", value)
+	}
+	if rate == 0 {
+		return 0, 0, nil
+	}
+	interval := float64(time.Second) / rate
+	if !(interval >= 1 && interval < math.MaxInt64) {
+		return 0, 0, fmt.Errorf("invalid value for --rate: %s, expected 0 (no limit) or 1.1e-10 to 1e9 requests/s", value)
+	}
+	return rate, time.Duration(interval), nil
+}
+
+// rateText describes the rate limit of a benchmark
+func rateText(rate float64) string {
+	if rate <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(", limited to %g requests/s", rate)
+}
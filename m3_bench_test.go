@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBenchRate(t *testing.T) {
+	tests := []struct {
+		value    string
+		interval time.Duration
+		wantErr  bool
+	}{
+		{value: "0"},
+		{value: "50", interval: 20 * time.Millisecond},
+		{value: "0.5", interval: 2 * time.Second},
+		{value: "1e9", interval: time.Nanosecond},
+		{value: "1.1e-10", interval: time.Duration(float64(time.Second) / 1.1e-10)},
+		{value: "2e9", wantErr: true},
+		{value: "1e-10", wantErr: true},
+		{value: "5e-324", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "NaN", wantErr: true},
+		{value: "+Inf", wantErr: true},
+		{value: "fast", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, interval, err := benchRate(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("benchRate(%q) = %s, want an error", tt.value, interval)
+			}
+			continue
+		}
+		if err != nil || interval != tt.interval {
+			t.Errorf("benchRate(%q) = %s, %v, want %s", tt.value, interval, err, tt.interval)
+		}
+	}
+}
+
+func TestBenchRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("case") {
+		case "throttled":
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "m3-error":
+			w.Write([]byte(`{"results": [{"transaction": "GetItmBasic", "errorMessage": "Item does not exist", "errorCode": "WITNO03"}]}`))
+		case "empty":
+			w.Write([]byte(`{"results": []}`))
+		case "html":
+			w.Write([]byte(`<html></html>`))
+		default:
+			writeM3Records(w, "GetItmBasic", []map[string]string{{"ITNO": "A1"}})
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		testCase string
+		errType  string
+	}{
+		{"ok", ""},
+		{"throttled", "HTTP 429 throttled"},
+		{"unavailable", "HTTP 503"},
+		{"m3-error", "M3 error WITNO03"},
+		{"empty", "no result"},
+		{"html", "invalid response"},
+	}
+	for _, tt := range tests {
+		sample := benchRequest(server.Client(), server.URL+"/?case="+tt.testCase, "test-token")
+		if sample.errType != tt.errType {
+			t.Errorf("benchRequest(%s) error type = %q, want %q", tt.testCase, sample.errType, tt.errType)
+		}
+	}
+
+	server.Close()
+	if sample := benchRequest(server.Client(), server.URL, "test-token"); sample.errType != "network" {
+		t.Errorf("benchRequest() to a closed server error type = %q, want network", sample.errType)
+	}
+}
+
+func TestSummarizeBench(t *testing.T) {
+	var samples []benchSample
+	for i := 1; i <= 100; i++ {
+		sample := benchSample{latency: time.Duration(i) * time.Millisecond}
+		if i%10 == 0 {
+			sample.errType = "HTTP 429 throttled"
+		}
+		samples = append(samples, sample)
+	}
+	summary := summarizeBench(samples, 10*time.Second)
+
+	if summary.Requests != 100 || summary.Succeeded != 90 || summary.Errors["HTTP 429 throttled"] != 10 {
+		t.Errorf("summary counts %d requests, %d succeeded, errors %v", summary.Requests, summary.Succeeded, summary.Errors)
+	}
+	if summary.Throughput != 10 || summary.ErrorRate != 0.1 {
+		t.Errorf("throughput %g, error rate %g, want 10 and 0.1", summary.Throughput, summary.ErrorRate)
+	}
+	want := map[string]float64{"min": 1, "mean": 50.5, "p50": 50, "p90": 90, "p95": 95, "p99": 99, "max": 100}
+	for name, ms := range want {
+		if summary.LatencyMS[name] != ms {
+			t.Errorf("latency %s = %g ms, want %g", name, summary.LatencyMS[name], ms)
+		}
+	}
+
+	if empty := summarizeBench(nil, time.Second); empty.Requests != 0 || len(empty.LatencyMS) != 0 {
+		t.Errorf("summary of no samples = %+v", empty)
+	}
+}
+
+func TestRunM3BenchRefusesWrites(t *testing.T) {
+	err := runM3Bench([]string{"missing.ionapi", "MMS200MI/AddItmBasic", "--duration", "1s"})
+	if err == nil || !strings.Contains(err.Error(), "only runs read transactions") {
+		t.Errorf("runM3Bench() error = %v, want the write transaction refused", err)
+	}
+	err = runM3Bench([]string{"missing.ionapi", "CMS535MI/FpwVersion", "--rate", "1e-10"})
+	if err == nil || !strings.Contains(err.Error(), "invalid value for --rate") {
+		t.Errorf("runM3Bench() error = %v, want the rate refused", err)
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// bulkRow is one transaction read from the bulk input file
+type bulkRow struct {
+	Line        int
+	Program     string
+	Transaction string
+	Record      map[string]string
+}
+
+// bulkRowResult is the outcome of one row, written to the output file
+type bulkRowResult struct {
+	Row         int                 `json:"row"`
+	Program     string              `json:"program"`
+	Transaction string              `json:"transaction"`
+	Status      string              `json:"status"`
+	MessageID   string              `json:"message_id,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	Records     []map[string]string `json:"records,omitempty"`
+}
+
+// Status of a bulk row
+const (
+	bulkOK           = "ok"
+	bulkFailed       = "failed"
+	bulkNotProcessed = "not processed"
+)
+
+// bulkSummary counts the outcome of a bulk run
+type bulkSummary struct {
+	Input        string  `json:"input"`
+	Output       string  `json:"output"`
+	Rows         int     `json:"rows"`
+	Batches      int     `json:"batches"`
+	Succeeded    int     `json:"succeeded"`
+	Failed       int     `json:"failed"`
+	NotProcessed int     `json:"not_processed"`
+	DurationMS   float64 `json:"duration_ms"`
+}
+
+// runM3Bulk executes the transactions of a CSV or JSON lines file through the multi-transaction
+// endpoint: m3 bulk <ionapi-file-path> <input-file> [--batch-size <n>] [--output <file>]
+func runM3Bulk(args []string) error {
+	cli := parseArgs(args, append(m3ValueFlags, "--batch-size", "--output")...)
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: m3 bulk <ionapi-file-path> <input-file> [--batch-size <n>] [--output <file>]")
+	}
+	applyGlobalFlags(cli)
+
+	batchSize, err := cli.intValue("--batch-size", 100)
+	if err != nil {
+		return err
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1")
+	}
+	opts, err := m3OptionsFromArgs(cli)
+	if err != nil {
+		return err
+	}
+
+	input := cli.positional[1]
+	rows, err := readBulkRows(input)
+	if err != nil {
+		return err
+	}
+	logger.Printf("📄 Read %d transaction(s) from %s", len(rows), input)
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newM3Client(token, ionAPI.IonBaseURL, ionAPI.TenantID)
+
+	output := cli.value("--output", strings.TrimSuffix(input, filepath.Ext(input))+".results"+bulkOutputExt(input))
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	writer := newBulkWriter(out, output)
+
+	summary := bulkSummary{Input: input, Output: output, Rows: len(rows)}
+	start := time.Now()
+	for _, batch := range bulkBatches(rows, batchSize) {
+		summary.Batches++
+		for _, result := range runBulkBatch(client, batch, opts) {
+			switch result.Status {
+			case bulkOK:
+				summary.Succeeded++
+			case bulkFailed:
+				summary.Failed++
+				logger.Printf("❌ Row %d %s/%s: %s", result.Row, result.Program, result.Transaction, result.Error)
+			default:
+				summary.NotProcessed++
+			}
+			if err := writer.write(result); err != nil {
+				return err
+			}
+		}
+		logger.Printf("📦 Batch %d done, %d/%d row(s) processed", summary.Batches, summary.Succeeded+summary.Failed+summary.NotProcessed, len(rows))
+	}
+	if err := writer.flush(); err != nil {
+		return err
+	}
+	summary.DurationMS = milliseconds(time.Since(start))
+	report.Bulk = &summary
+
+	logger.Printf("✅ Bulk run finished: %d succeeded, %d failed, %d not processed in %s", summary.Succeeded, summary.Failed,
+		summary.NotProcessed, roundDuration(time.Since(start)))
+	logger.Printf("📝 Results written to %s", output)
+	finishReport()
+	return nil
+}
+
+// runBulkBatch sends one batch and maps the results back to the rows, in order
+func runBulkBatch(client *m3Client, batch []bulkRow, opts m3Options) []bulkRowResult {
+	transactions := make([]m3Transaction, len(batch))
+	for i, row := range batch {
+		transactions[i] = m3Transaction{Transaction: row.Transaction, Record: row.Record}
+	}
+
+	results := make([]bulkRowResult, len(batch))
+	for i, row := range batch {
+		results[i] = bulkRowResult{Row: row.Line, Program: row.Program, Transaction: row.Transaction, Status: bulkNotProcessed}
+	}
+
+	response, err := client.ExecuteMulti(batch[0].Program, transactions, opts)
+	if err != nil {
+		for i := range results {
+			results[i].Status = bulkFailed
+			results[i].Error = err.Error()
+		}
+		return results
+	}
+
+	for i := range results {
+		if i >= len(response.Results) {
+			if response.WasTerminated {
+				results[i].Error = strings.TrimSpace(response.TerminationErrorType + " " + response.TerminationReason)
+			}
+			continue
+		}
+		result := response.Results[i]
+		if err := result.Err(batch[i].Program); err != nil {
+			results[i].Status = bulkFailed
+			results[i].Error = err.Error()
+			results[i].MessageID = result.ErrorCode
+			continue
+		}
+		results[i].Status = bulkOK
+		results[i].Records = result.Records
+	}
+	return results
+}
+
+// bulkBatches splits the rows into batches of at most size rows. The multi-transaction
+// endpoint takes a single program, so a new batch is also started when the program changes.
+func bulkBatches(rows []bulkRow, size int) [][]bulkRow {
+	var batches [][]bulkRow
+	var current []bulkRow
+	for _, row := range rows {
+		if len(current) > 0 && (len(current) >= size || current[0].Program != row.Program) {
+			batches = append(batches, current)
+			current = nil
+		}
+		current = append(current, row)
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// readBulkRows reads transactions from a CSV file (by extension) or a JSON lines file
+func readBulkRows(path string) ([]bulkRow, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readBulkCSV(file)
+	}
+	return readBulkJSONL(file)
+}
+
+// readBulkCSV reads a CSV file with a header. The program and transaction columns name the
+// transaction (or a transaction column holding PROGRAM/TRANSACTION), all other columns are
+// input fields. Empty cells are not sent.
+func readBulkCSV(r io.Reader) ([]bulkRow, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %v", err)
+	}
+
+	var rows []bulkRow
+	for line := 2; ; line++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := bulkRow{Line: line, Record: map[string]string{}}
+		for i, name := range header {
+			if i >= len(values) || values[i] == "" {
+				continue
+			}
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case "program":
+				row.Program = values[i]
+			case "transaction":
+				row.Transaction = values[i]
+			default:
+				row.Record[strings.ToUpper(strings.TrimSpace(name))] = values[i]
+			}
+		}
+		if err := row.normalize(); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readBulkJSONL reads one JSON object per line:
+// {"program": "MMS200MI", "transaction": "GetItmBasic", "record": {"ITNO": "A"}}
+func readBulkJSONL(r io.Reader) ([]bulkRow, error) {
+	var rows []bulkRow
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var entry struct {
+			Program     string            `json:"program"`
+			Transaction string            `json:"transaction"`
+			Record      map[string]string `json:"record"`
+		}
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		row := bulkRow{Line: line, Program: entry.Program, Transaction: entry.Transaction, Record: map[string]string{}}
+		for key, value := range entry.Record {
+			row.Record[strings.ToUpper(key)] = value
+		}
+		if err := row.normalize(); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// normalize splits a PROGRAM/TRANSACTION transaction and checks the row names a transaction
+func (row *bulkRow) normalize() error {
+	if row.Program == "" && strings.Contains(row.Transaction, "/") {
+		program, transaction, err := parseTransaction(row.Transaction)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", row.Line, err)
+		}
+		row.Program, row.Transaction = program, transaction
+	}
+	if row.Program == "" || row.Transaction == "" {
+		return fmt.Errorf("line %d: program and transaction are required", row.Line)
+	}
+	row.Program = strings.ToUpper(row.Program)
+	return nil
+}
+
+// bulkWriter writes row results as JSON lines or CSV
+type bulkWriter struct {
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+// newBulkWriter creates a writer for the output file, CSV if its name ends in .csv
+func newBulkWriter(w io.Writer, path string) *bulkWriter {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"row", "program", "transaction", "status", "message_id", "error", "records"})
+		return &bulkWriter{csv: writer}
+	}
+	return &bulkWriter{json: json.NewEncoder(w)}
+}
+
+// write writes the result of one row
+func (w *bulkWriter) write(result bulkRowResult) error {
+	if w.json != nil {
+		return w.json.Encode(result)
+	}
+	records := ""
+	if len(result.Records) > 0 {
+		data, err := json.Marshal(result.Records)
+		if err != nil {
+			return err
+		}
+		records = string(data)
+	}
+	return w.csv.Write([]string{fmt.Sprint(result.Row), result.Program, result.Transaction, result.Status,
+		result.MessageID, result.Error, records})
+}
+
+// flush flushes buffered CSV output
+func (w *bulkWriter) flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
+
+// bulkOutputExt returns the extension of the default output file, matching the input format
+func bulkOutputExt(input string) string {
+	if strings.EqualFold(filepath.Ext(input), ".csv") {
+		return ".csv"
+	}
+	return ".jsonl"
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBulkBatches(t *testing.T) {
+	rows := func(programs ...string) []bulkRow {
+		result := make([]bulkRow, len(programs))
+		for i, program := range programs {
+			result[i] = bulkRow{Line: i + 1, Program: program, Transaction: "AddItmBasic"}
+		}
+		return result
+	}
+	tests := []struct {
+		name string
+		rows []bulkRow
+		size int
+		want [][]int
+	}{
+		{"empty", nil, 10, nil},
+		{"one batch", rows("MMS200MI", "MMS200MI"), 10, [][]int{{1, 2}}},
+		{"split by size", rows("MMS200MI", "MMS200MI", "MMS200MI"), 2, [][]int{{1, 2}, {3}}},
+		{"exact size", rows("MMS200MI", "MMS200MI"), 2, [][]int{{1, 2}}},
+		{"split by program", rows("MMS200MI", "CRS610MI", "CRS610MI", "MMS200MI"), 10, [][]int{{1}, {2, 3}, {4}}},
+		{"size one", rows("MMS200MI", "MMS200MI"), 1, [][]int{{1}, {2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]int
+			for _, batch := range bulkBatches(tt.rows, tt.size) {
+				var lines []int
+				for _, row := range batch {
+					if row.Program != batch[0].Program {
+						t.Errorf("batch mixes programs %s and %s", batch[0].Program, row.Program)
+					}
+					lines = append(lines, row.Line)
+				}
+				got = append(got, lines)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("bulkBatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
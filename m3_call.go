@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// m3Commands maps the subcommands of the m3 command to the functions running them
+var m3Commands = map[string]func(args []string) error{
+	"call":         runM3Call,
+	"bulk":         runM3Bulk,
+	"programs":     runM3Programs,
+	"transactions": runM3Transactions,
+	"fields":       runM3Fields,
+	"codegen":      runM3Codegen,
+	"export":       runM3Export,
+	"info":         runM3Info,
+	"bench":        runM3Bench,
+}
+
+// runM3 runs an m3 subcommand
+func runM3(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: m3 <subcommand> [arguments], see the usage of Infor-test")
+	}
+	command, ok := m3Commands[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown m3 subcommand %q", args[0])
+	}
+	if err := configureM3Policy(parseArgs(args[1:], m3ValueFlags...)); err != nil {
+		return err
+	}
+	return command(args[1:])
+}
+
+// m3ValueFlags are the flags of the m3 subcommands that take a value
+var m3ValueFlags = []string{"--param", "--dateformat", "--maxrecs", "--returncols", "--cono", "--divi", "--format", "--policy", "--allow-write"}
+
+// runM3Call executes a single MI transaction: m3 call <ionapi-file-path> <PROGRAM>/<TRANSACTION> --param KEY=VALUE ...
+func runM3Call(args []string) error {
+	cli := parseArgs(args, m3ValueFlags...)
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: m3 call <ionapi-file-path> <PROGRAM>/<TRANSACTION> [--param KEY=VALUE ...] [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	reserveStdout(cli.value("--format", "table"))
+
+	program, transaction, err := parseTransaction(cli.positional[1])
+	if err != nil {
+		return err
+	}
+	params, err := parseParams(cli.values("--param"))
+	if err != nil {
+		return err
+	}
+	opts, err := m3OptionsFromArgs(cli)
+	if err != nil {
+		return err
+	}
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+
+	result, err := newM3Client(token, ionAPI.IonBaseURL, ionAPI.TenantID).Call(program, transaction, params, opts)
+	if err != nil {
+		return err
+	}
+	logger.Printf("✅ %s/%s returned %d record(s)", program, transaction, len(result.Records))
+
+	if cli.has("--metadata") {
+		if err := printColumns(logger.Writer(), m3Columns(result)); err != nil {
+			return err
+		}
+		fmt.Fprintln(logger.Writer())
+	}
+	if err := printRecords(os.Stdout, cli.value("--format", "table"), m3Columns(result), result.Records); err != nil {
+		return err
+	}
+	finishReport()
+	return nil
+}
+
+// parseTransaction splits PROGRAM/TRANSACTION
+func parseTransaction(arg string) (string, string, error) {
+	program, transaction, ok := strings.Cut(arg, "/")
+	if !ok || program == "" || transaction == "" {
+		return "", "", fmt.Errorf("invalid transaction %q, expected PROGRAM/TRANSACTION such as CMS535MI/FpwVersion", arg)
+	}
+	return strings.ToUpper(program), transaction, nil
+}
+
+// parseParams parses KEY=VALUE input fields
+func parseParams(values []string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, value := range values {
+		key, fieldValue, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected KEY=VALUE", value)
+		}
+		params[strings.ToUpper(key)] = fieldValue
+	}
+	return params, nil
+}
+
+// m3OptionsFromArgs reads the execute options from the command line
+func m3OptionsFromArgs(cli cliArgs) (m3Options, error) {
+	opts := defaultM3Options
+	opts.DateFormat = cli.value("--dateformat", opts.DateFormat)
+	opts.Company = cli.value("--cono", "")
+	opts.Division = cli.value("--divi", "")
+	if cols := cli.value("--returncols", ""); cols != "" {
+		opts.ReturnCols = strings.Split(strings.ToUpper(cols), ",")
+	}
+
+	var err error
+	if opts.MaxRecs, err = cli.intValue("--maxrecs", 0); err != nil {
+		return opts, err
+	}
+	if opts.ExcludeEmpty, err = cli.boolValue("--excludeempty", opts.ExcludeEmpty); err != nil {
+		return opts, err
+	}
+	if opts.RightTrim, err = cli.boolValue("--righttrim", opts.RightTrim); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// m3Check is an MI call with the assertions its result must meet
+type m3Check struct {
+	Name        string            `json:"name"`
+	Transaction string            `json:"transaction"`
+	Params      map[string]string `json:"params"`
+	Company     string            `json:"cono"`
+	Division    string            `json:"divi"`
+	Expect      m3Expectation     `json:"expect"`
+}
+
+// m3Expectation lists the assertions of a check. Field assertions apply to the first record.
+type m3Expectation struct {
+	// Status is "ok" (default) or "error" when the transaction is expected to return an M3 error
+	Status       string            `json:"status"`
+	MessageID    string            `json:"message_id"`
+	NonEmpty     bool              `json:"non_empty"`
+	Fields       map[string]string `json:"fields"`
+	Matches      map[string]string `json:"matches"`
+	MaxLatencyMS float64           `json:"max_latency_ms"`
+}
+
+// m3CheckSuite is the content of a --m3_checks file
+type m3CheckSuite struct {
+	Checks []m3Check `json:"checks"`
+}
+
+// m3CheckResult is the outcome of a check, added to the report
+type m3CheckResult struct {
+	Name        string   `json:"name"`
+	Transaction string   `json:"transaction"`
+	Passed      bool     `json:"passed"`
+	Records     int      `json:"records"`
+	LatencyMS   float64  `json:"latency_ms"`
+	Failures    []string `json:"failures,omitempty"`
+}
+
+// loadM3CheckSuite reads and validates a check suite file
+func loadM3CheckSuite(path string) (*m3CheckSuite, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var suite m3CheckSuite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse M3 checks %s: %v", path, err)
+	}
+	for i, check := range suite.Checks {
+		if _, _, err := parseTransaction(check.Transaction); err != nil {
+			return nil, fmt.Errorf("check %d: %v", i+1, err)
+		}
+		if status := strings.ToLower(check.Expect.Status); status != "" && status != "ok" && status != "error" {
+			return nil, fmt.Errorf("check %d: invalid status %q, expected ok or error", i+1, check.Expect.Status)
+		}
+		for field, pattern := range check.Expect.Matches {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("check %d: invalid pattern for %s: %v", i+1, field, err)
+			}
+		}
+		if suite.Checks[i].Name == "" {
+			suite.Checks[i].Name = check.Transaction
+		}
+	}
+	return &suite, nil
+}
+
+// runM3Checks runs every check of the suite and returns the number of failed checks
+func runM3Checks(client *m3Client, suite *m3CheckSuite) int {
+	failed := 0
+	for _, check := range suite.Checks {
+		result := runM3Check(client, check)
+		report.addM3Check(result)
+		if result.Passed {
+			logger.Printf("✅ M3 check %s passed (%d record(s), %.0f ms)", result.Name, result.Records, result.LatencyMS)
+			continue
+		}
+		failed++
+		logger.Printf("❌ M3 check %s failed: %s", result.Name, strings.Join(result.Failures, "; "))
+	}
+	return failed
+}
+
+// runM3Check calls the transaction of a check and evaluates its assertions
+func runM3Check(client *m3Client, check m3Check) m3CheckResult {
+	result := m3CheckResult{Name: check.Name, Transaction: check.Transaction}
+	program, transaction, _ := parseTransaction(check.Transaction)
+
+	opts := defaultM3Options
+	opts.Company = check.Company
+	opts.Division = check.Division
+	start := time.Now()
+	response, err := client.Call(program, transaction, check.Params, opts)
+	result.LatencyMS = milliseconds(time.Since(start))
+
+	fail := func(format string, args ...interface{}) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+
+	expect := check.Expect
+	if strings.EqualFold(expect.Status, "error") {
+		m3Err, ok := err.(*m3Error)
+		switch {
+		case !ok && err != nil:
+			fail("expected an M3 error, got %v", err)
+		case !ok:
+			fail("expected an M3 error, the transaction succeeded")
+		case expect.MessageID != "" && m3Err.MessageID != expect.MessageID:
+			fail("expected message %s, got %s", expect.MessageID, m3Err.Error())
+		}
+	} else if err != nil {
+		fail("%v", err)
+	}
+
+	if response != nil {
+		result.Records = len(response.Records)
+		if expect.NonEmpty && len(response.Records) == 0 {
+			fail("no records returned")
+		}
+		var first map[string]string
+		if len(response.Records) > 0 {
+			first = response.Records[0]
+		}
+		for _, field := range sortedKeys(expect.Fields) {
+			if value, ok := first[field]; !ok || strings.TrimSpace(value) != expect.Fields[field] {
+				fail("%s is %q, expected %q", field, value, expect.Fields[field])
+			}
+		}
+		for _, field := range sortedKeys(expect.Matches) {
+			if value := first[field]; !regexp.MustCompile(expect.Matches[field]).MatchString(value) {
+				fail("%s is %q, expected to match %s", field, value, expect.Matches[field])
+			}
+		}
+	}
+
+	if expect.MaxLatencyMS > 0 && result.LatencyMS > expect.MaxLatencyMS {
+		fail("took %.0f ms, expected at most %.0f ms", result.LatencyMS, expect.MaxLatencyMS)
+	}
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// sortedKeys returns the keys of a map in order, so failures are reported in a stable order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"inforconnectiontest/m3api"
+)
+
+func TestLoadM3CheckSuite(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{name: "valid", content: `{"checks": [{"transaction": "CRS610MI/GetBasicData", "expect": {"status": "OK"}}, {"name": "Item", "transaction": "MMS200MI/GetItmBasic", "expect": {"status": "error"}}]}`},
+		{name: "not JSON", content: `checks:`, wantErr: "failed to parse M3 checks"},
+		{name: "no transaction", content: `{"checks": [{"transaction": "CRS610MI"}]}`, wantErr: "check 1:"},
+		{name: "invalid status", content: `{"checks": [{"transaction": "CRS610MI/GetBasicData", "expect": {"status": "warning"}}]}`, wantErr: `invalid status "warning"`},
+		{name: "invalid pattern", content: `{"checks": [{"transaction": "CRS610MI/GetBasicData", "expect": {"matches": {"STAT": "[0-9"}}}]}`, wantErr: "invalid pattern for STAT"},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.Repeat("c", i+1)+".json")
+			if err := ioutil.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			suite, err := loadM3CheckSuite(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("loadM3CheckSuite() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Checks without a name are named after their transaction
+			if suite.Checks[0].Name != "CRS610MI/GetBasicData" || suite.Checks[1].Name != "Item" {
+				t.Errorf("check names %q and %q", suite.Checks[0].Name, suite.Checks[1].Name)
+			}
+		})
+	}
+}
+
+func TestRunM3Checks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/ACME_TST/M3/m3api-rest/v2/execute/") {
+		case "CRS610MI/GetBasicData":
+			if r.URL.Query().Get("cono") != "100" {
+				http.Error(w, "company not set", http.StatusBadRequest)
+				return
+			}
+			writeM3Records(w, "GetBasicData", []map[string]string{{"CUNO": "C1", "CUNM": "ACME Corp ", "STAT": "20"}})
+		case "CRS610MI/LstByNumber":
+			writeM3Records(w, "LstByNumber", nil)
+		case "MMS200MI/GetItmBasic":
+			w.Write([]byte(`{"results": [{"transaction": "GetItmBasic", "errorMessage": "Item does not exist", "errorCode": "WITNO03"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := m3api.NewClient("test-token", server.URL, "ACME_TST")
+	defer func(saved []m3CheckResult) { report.M3Checks = saved }(report.M3Checks)
+	report.M3Checks = nil
+
+	suite := &m3CheckSuite{Checks: []m3Check{
+		{Name: "customer", Transaction: "CRS610MI/GetBasicData", Company: "100", Expect: m3Expectation{
+			NonEmpty: true, Fields: map[string]string{"CUNM": "ACME Corp"}, Matches: map[string]string{"STAT": "^[12]0$"}}},
+		{Name: "customer fields", Transaction: "CRS610MI/GetBasicData", Company: "100", Expect: m3Expectation{
+			Fields: map[string]string{"STAT": "90", "CUCD": "EUR"}, Matches: map[string]string{"CUNO": "^X"}}},
+		{Name: "empty list", Transaction: "CRS610MI/LstByNumber", Expect: m3Expectation{NonEmpty: true}},
+		{Name: "missing item", Transaction: "MMS200MI/GetItmBasic", Expect: m3Expectation{Status: "error", MessageID: "WITNO03"}},
+		{Name: "other message", Transaction: "MMS200MI/GetItmBasic", Expect: m3Expectation{Status: "error", MessageID: "WITNO01"}},
+		{Name: "unexpected error", Transaction: "MMS200MI/GetItmBasic"},
+		{Name: "expected error", Transaction: "CRS610MI/LstByNumber", Expect: m3Expectation{Status: "error"}},
+		{Name: "HTTP error", Transaction: "CRS610MI/GetBasicData", Expect: m3Expectation{Status: "error"}},
+	}}
+	if failed := runM3Checks(client, suite); failed != 6 {
+		t.Errorf("runM3Checks() = %d failed, want 6", failed)
+	}
+
+	want := map[string][]string{
+		"customer": nil,
+		"customer fields": {
+			`CUCD is "", expected "EUR"`,
+			`STAT is "20", expected "90"`,
+			`CUNO is "C1", expected to match ^X`,
+		},
+		"empty list":       {"no records returned"},
+		"missing item":     nil,
+		"other message":    {"expected message WITNO01, got MMS200MI/GetItmBasic failed [WITNO03]: Item does not exist"},
+		"unexpected error": {"MMS200MI/GetItmBasic failed [WITNO03]: Item does not exist"},
+		"expected error":   {"expected an M3 error, the transaction succeeded"},
+		"HTTP error":       {"expected an M3 error, got API request failed with status: 400 Bad Request"},
+	}
+	if len(report.M3Checks) != len(want) {
+		t.Fatalf("report has %d check results, want %d", len(report.M3Checks), len(want))
+	}
+	for _, result := range report.M3Checks {
+		if !reflect.DeepEqual(result.Failures, want[result.Name]) || result.Passed != (want[result.Name] == nil) {
+			t.Errorf("check %s passed %v with failures %q, want %q", result.Name, result.Passed, result.Failures, want[result.Name])
+		}
+	}
+}
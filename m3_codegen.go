@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// codegenField is a field of a generated request or response type
+type codegenField struct {
+	Name      string
+	GoName    string
+	GoType    string
+	Comment   string
+	Mandatory bool
+}
+
+// codegenTransaction holds what the template needs to generate the types and call function of a transaction
+type codegenTransaction struct {
+	Program     string
+	Transaction string
+	Description string
+	TypeName    string
+	Inputs      []codegenField
+	Outputs     []codegenField
+}
+
+// codegenTemplate generates request and response types plus a call function per transaction, on top of
+// the m3api package. Numeric fields (type N) are float64, all others string. Optional inputs are only sent
+// when set. Transactions without output fields, like most Add, Upd and Dlt transactions, return empty records.
+var codegenTemplate = template.Must(template.New("m3").Parse(`// Code generated by Infor-test m3 codegen from the M3 metadata of {{.Program}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import "inforconnectiontest/m3api"
+{{range .Transactions}}
+// {{.TypeName}}Request is the input of {{.Program}}/{{.Transaction}}{{if .Description}}: {{.Description}}{{end}}
+type {{.TypeName}}Request struct {
+{{- range .Inputs}}
+	{{.GoName}} {{.GoType}} // {{.Comment}}
+{{- end}}
+}
+
+// {{.TypeName}}Response is a record returned by {{.Program}}/{{.Transaction}}
+type {{.TypeName}}Response struct {
+{{- range .Outputs}}
+	{{.GoName}} {{.GoType}} // {{.Comment}}
+{{- end}}
+}
+
+// params returns the input fields of the request, optional fields are left out when not set
+func (r {{.TypeName}}Request) params() map[string]string {
+	params := map[string]string{}
+{{- range .Inputs}}
+{{- if .Mandatory}}
+	params["{{.Name}}"] = {{if eq .GoType "float64"}}m3api.FormatNumber(r.{{.GoName}}){{else}}r.{{.GoName}}{{end}}
+{{- else if eq .GoType "float64"}}
+	if r.{{.GoName}} != 0 {
+		params["{{.Name}}"] = m3api.FormatNumber(r.{{.GoName}})
+	}
+{{- else}}
+	if r.{{.GoName}} != "" {
+		params["{{.Name}}"] = r.{{.GoName}}
+	}
+{{- end}}
+{{- end}}
+	return params
+}
+
+// {{.TypeName}} calls {{.Program}}/{{.Transaction}}{{if .Description}}: {{.Description}}{{end}}
+func {{.TypeName}}(c *m3api.Client, req {{.TypeName}}Request, opts m3api.Options) ([]{{.TypeName}}Response, error) {
+	result, err := c.Call("{{.Program}}", "{{.Transaction}}", req.params(), opts)
+	if err != nil {
+		return nil, err
+	}
+	responses := make([]{{.TypeName}}Response, len(result.Records))
+{{- if .Outputs}}
+	for i, record := range result.Records {
+{{- range .Outputs}}
+		responses[i].{{.GoName}} = {{if eq .GoType "float64"}}m3api.Number(record["{{.Name}}"]){{else}}record["{{.Name}}"]{{end}}
+{{- end}}
+	}
+{{- end}}
+	return responses, nil
+}
+{{end}}`))
+
+// runM3Codegen generates Go types and call functions for the transactions of a program:
+// m3 codegen <ionapi-file-path> <PROGRAM> [--transactions A,B] [--package <name>] [--output <file>]
+func runM3Codegen(args []string) error {
+	cli := parseArgs(args, append(m3ValueFlags, "--transactions", "--package", "--output", "--cache-dir")...)
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: m3 codegen <ionapi-file-path> <PROGRAM> [--transactions A,B] [--package <name>] [--output <file>] [--refresh]")
+	}
+	applyGlobalFlags(cli)
+
+	program := strings.ToUpper(cli.positional[1])
+	pkg := cli.value("--package", strings.ToLower(program))
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name %q", pkg)
+	}
+	catalog, err := m3CatalogFromArgs(cli)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if list := cli.value("--transactions", ""); list != "" {
+		names = strings.Split(list, ",")
+	} else {
+		transactions, err := catalog.transactions(program)
+		if err != nil {
+			return err
+		}
+		for _, transaction := range transactions {
+			names = append(names, transaction.Name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no transactions found for %s", program)
+	}
+
+	var transactions []codegenTransaction
+	for _, name := range names {
+		info, err := catalog.transaction(program, strings.TrimSpace(name))
+		if err != nil {
+			return err
+		}
+		transactions = append(transactions, codegenTransaction{
+			Program:     program,
+			Transaction: info.Name,
+			Description: info.Description,
+			TypeName:    goIdentifier(program + info.Name),
+			Inputs:      codegenFields(info.Inputs),
+			Outputs:     codegenFields(info.Outputs),
+		})
+		logger.Printf("🧩 %s/%s: %d input and %d output field(s)", program, info.Name, len(info.Inputs), len(info.Outputs))
+	}
+
+	source, err := generateM3Code(pkg, program, transactions)
+	if err != nil {
+		return err
+	}
+
+	output := cli.value("--output", "m3_"+strings.ToLower(program)+"_gen.go")
+	if err := ioutil.WriteFile(output, source, 0644); err != nil {
+		return err
+	}
+	logger.Printf("📝 Generated %d transaction(s) of %s in %s", len(transactions), program, output)
+	finishReport()
+	return nil
+}
+
+// generateM3Code executes codegenTemplate for the transactions of a program and formats the code
+func generateM3Code(pkg, program string, transactions []codegenTransaction) ([]byte, error) {
+	var buf bytes.Buffer
+	err := codegenTemplate.Execute(&buf, map[string]interface{}{
+		"Package":      pkg,
+		"Program":      program,
+		"Transactions": transactions,
+	})
+	if err != nil {
+		return nil, err
+	}
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %v", err)
+	}
+	return source, nil
+}
+
+// codegenFields maps metadata fields to generated struct fields, skipping repeated field names
+func codegenFields(fields []m3FieldInfo) []codegenField {
+	seen := map[string]bool{}
+	var generated []codegenField
+	for _, field := range fields {
+		goName := goIdentifier(field.Name)
+		if goName == "" || seen[goName] {
+			continue
+		}
+		seen[goName] = true
+
+		goType := "string"
+		if field.numeric() {
+			goType = "float64"
+		}
+		comment := field.Description
+		if comment == "" {
+			comment = field.Name
+		}
+		if field.Length > 0 {
+			comment += fmt.Sprintf(", %s%d", field.Type, field.Length)
+		}
+		if field.Mandatory {
+			comment += ", mandatory"
+		}
+		generated = append(generated, codegenField{Name: field.Name, GoName: goName, GoType: goType, Comment: comment, Mandatory: field.Mandatory})
+	}
+	return generated
+}
+
+// goIdentifier turns a program, transaction or field name into an exported Go identifier
+func goIdentifier(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	identifier := b.String()
+	if identifier == "" {
+		return ""
+	}
+	if first := rune(identifier[0]); !unicode.IsLetter(first) {
+		identifier = "F" + identifier
+	}
+	return strings.ToUpper(identifier[:1]) + identifier[1:]
+}
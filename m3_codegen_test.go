@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerateM3CodeBuilds generates a program with read and write transactions into a package of
+// this module and builds it against the m3api package
+func TestGenerateM3CodeBuilds(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go tool is not available")
+	}
+	transactions := []codegenTransaction{
+		{
+			Program: "MMS200MI", Transaction: "GetItmBasic", Description: "Get item basic data", TypeName: "MMS200MIGetItmBasic",
+			Inputs: codegenFields([]m3FieldInfo{
+				{column: column{Name: "CONO", Type: "N", Length: 3}},
+				{column: column{Name: "ITNO", Type: "A", Length: 15}, Mandatory: true},
+			}),
+			Outputs: codegenFields([]m3FieldInfo{
+				{column: column{Name: "ITNO", Type: "A", Length: 15}},
+				{column: column{Name: "GRWE", Type: "N", Length: 9}},
+			}),
+		},
+		{
+			Program: "MMS200MI", Transaction: "DltItmBasic", TypeName: "MMS200MIDltItmBasic",
+			Inputs: codegenFields([]m3FieldInfo{{column: column{Name: "ITNO", Type: "A", Length: 15}, Mandatory: true}}),
+		},
+		{Program: "MMS200MI", Transaction: "Ping", TypeName: "MMS200MIPing"},
+	}
+	source, err := generateM3Code("mms200mi", "MMS200MI", transactions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(source), "package main") {
+		t.Error("generated code is package main, want mms200mi")
+	}
+
+	dir, err := ioutil.TempDir(".", "codegen-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "mms200mi_gen.go"), source, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if output, err := exec.Command(goTool, "vet", "./"+filepath.Base(dir)).CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not build: %v\n%s\n%s", err, output, source)
+	}
+}
+
+func TestGoIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"ITNO", "ITNO"},
+		{"MMS200MIGetItmBasic", "MMS200MIGetItmBasic"},
+		{"itno", "Itno"},
+		{"@ITDS", "ITDS"},
+		{"1ST", "F1ST"},
+		{"#-", ""},
+	}
+	for _, tt := range tests {
+		if got := goIdentifier(tt.name); got != tt.want {
+			t.Errorf("goIdentifier(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportState is saved next to the output file after every page, so an interrupted export
+// can be resumed with --resume
+type exportState struct {
+	Program     string            `json:"program"`
+	Transaction string            `json:"transaction"`
+	Params      map[string]string `json:"params"`
+	Keys        []string          `json:"keys"`
+	Columns     []column          `json:"columns"`
+	LastKey     map[string]string `json:"last_key,omitempty"`
+	LastKeyRows int               `json:"last_key_rows,omitempty"`
+	Rows        int               `json:"rows"`
+	Pages       int               `json:"pages"`
+	Size        int64             `json:"size"`
+	Done        bool              `json:"done"`
+}
+
+// exportSummary is the outcome of an export, added to the report
+type exportSummary struct {
+	Program     string  `json:"program"`
+	Transaction string  `json:"transaction"`
+	Output      string  `json:"output"`
+	Rows        int     `json:"rows"`
+	Pages       int     `json:"pages"`
+	Resumed     bool    `json:"resumed"`
+	DurationMS  float64 `json:"duration_ms"`
+	RowsPerSec  float64 `json:"rows_per_second"`
+}
+
+// runM3Export pages through a list transaction using key continuation and streams the records to a file:
+// m3 export <ionapi-file-path> <PROGRAM>/<TRANSACTION> --key FIELD[,FIELD] [--page-size <n>] [--output <file>] [--resume]
+func runM3Export(args []string) error {
+	cli := parseArgs(args, append(m3ValueFlags, "--key", "--page-size", "--output")...)
+	if len(cli.positional) < 2 || !cli.has("--key") {
+		return fmt.Errorf("usage: m3 export <ionapi-file-path> <PROGRAM>/<TRANSACTION> --key FIELD[,FIELD] [--param KEY=VALUE ...] [--page-size <n>] [--output <file>] [--resume]")
+	}
+	applyGlobalFlags(cli)
+
+	program, transaction, err := parseTransaction(cli.positional[1])
+	if err != nil {
+		return err
+	}
+	params, err := parseParams(cli.values("--param"))
+	if err != nil {
+		return err
+	}
+	opts, err := m3OptionsFromArgs(cli)
+	if err != nil {
+		return err
+	}
+	if opts.MaxRecs, err = cli.intValue("--page-size", 1000); err != nil {
+		return err
+	}
+	if opts.MaxRecs < 2 {
+		return fmt.Errorf("--page-size must be at least 2")
+	}
+
+	output := cli.value("--output", strings.ToLower(program+"_"+transaction)+".jsonl")
+	statePath := output + ".state"
+	state := exportState{
+		Program:     program,
+		Transaction: transaction,
+		Params:      params,
+		Keys:        strings.Split(strings.ToUpper(cli.value("--key", "")), ","),
+	}
+
+	resumed := false
+	if cli.has("--resume") {
+		if resumed, err = loadExportState(statePath, &state); err != nil {
+			return err
+		}
+		if state.Done {
+			logger.Printf("✅ Export to %s is already complete (%d rows)", output, state.Rows)
+			return nil
+		}
+	}
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newM3Client(token, ionAPI.IonBaseURL, ionAPI.TenantID)
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resumed {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		info, err := os.Stat(output)
+		if err != nil {
+			return fmt.Errorf("cannot resume the export: %v", err)
+		}
+		if info.Size() < state.Size {
+			return fmt.Errorf("cannot resume the export: %s has %d bytes, fewer than the %d saved in %s, start the export again without --resume",
+				output, info.Size(), state.Size, statePath)
+		}
+		// Drop whatever was written after the last saved page
+		if err := os.Truncate(output, state.Size); err != nil {
+			return err
+		}
+		logger.Printf("⏯️ Resuming export of %s/%s after %d row(s), last key %v", program, transaction, state.Rows, state.LastKey)
+	} else if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		// The state of an earlier export does not match the new output
+		return err
+	}
+	out, err := os.OpenFile(output, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var writer *exportWriter
+	start := time.Now()
+	startRows := state.Rows
+	for {
+		page := map[string]string{}
+		for key, value := range state.Params {
+			page[key] = value
+		}
+		for key, value := range state.LastKey {
+			page[key] = value
+		}
+
+		result, err := client.Call(program, transaction, page, opts)
+		if err != nil {
+			return fmt.Errorf("page %d failed: %v", state.Pages+1, err)
+		}
+
+		records := skipWrittenRecords(result.Records, state)
+		lastKeyRows := trailingKeyRows(result.Records, state.Keys)
+		if lastKeyRows == opts.MaxRecs {
+			return fmt.Errorf("all %d records of a page have the same key, the key is not unique enough: add fields to --key or raise --page-size", lastKeyRows)
+		}
+
+		if state.Columns == nil {
+			state.Columns = m3Columns(result)
+		}
+		if writer == nil {
+			writer = newExportWriter(out, output, state.Columns, !resumed)
+		}
+		for _, record := range records {
+			if err := writer.write(record); err != nil {
+				return err
+			}
+		}
+		if err := writer.flush(); err != nil {
+			return err
+		}
+
+		state.Pages++
+		state.Rows += len(records)
+		if len(records) > 0 {
+			state.LastKey = map[string]string{}
+			for _, key := range state.Keys {
+				state.LastKey[key] = records[len(records)-1][key]
+			}
+			state.LastKeyRows = lastKeyRows
+		}
+		info, err := out.Stat()
+		if err != nil {
+			return err
+		}
+		state.Size = info.Size()
+		state.Done = len(result.Records) < opts.MaxRecs || len(records) == 0
+		if err := saveExportState(statePath, state); err != nil {
+			return err
+		}
+
+		elapsed := time.Since(start)
+		logger.Printf("📦 Page %d: %d row(s), %d total, %.0f rows/s", state.Pages, len(records), state.Rows,
+			float64(state.Rows-startRows)/elapsed.Seconds())
+		if state.Done {
+			break
+		}
+	}
+
+	elapsed := time.Since(start)
+	summary := exportSummary{
+		Program:     program,
+		Transaction: transaction,
+		Output:      output,
+		Rows:        state.Rows,
+		Pages:       state.Pages,
+		Resumed:     resumed,
+		DurationMS:  milliseconds(elapsed),
+		RowsPerSec:  float64(state.Rows-startRows) / elapsed.Seconds(),
+	}
+	report.Export = &summary
+	logger.Printf("✅ Exported %d row(s) of %s/%s in %d page(s) in %s (%.0f rows/s)", state.Rows, program, transaction,
+		state.Pages, roundDuration(elapsed), summary.RowsPerSec)
+	logger.Printf("📝 Records written to %s", output)
+	finishReport()
+	return nil
+}
+
+// sameKey reports whether the record has the given values for all key fields
+func sameKey(record, key map[string]string, fields []string) bool {
+	for _, field := range fields {
+		if record[field] != key[field] {
+			return false
+		}
+	}
+	return true
+}
+
+// skipWrittenRecords drops the records of a page that were already written. A page starts at the
+// last key of the previous one, so its first records with that key are skipped, as many as were
+// written; further records with the same key are kept.
+func skipWrittenRecords(records []map[string]string, state exportState) []map[string]string {
+	if state.LastKey == nil {
+		return records
+	}
+	skip := 0
+	for skip < len(records) && skip < state.LastKeyRows && sameKey(records[skip], state.LastKey, state.Keys) {
+		skip++
+	}
+	return records[skip:]
+}
+
+// trailingKeyRows returns the number of records at the end of a page that have the key of the last record
+func trailingKeyRows(records []map[string]string, fields []string) int {
+	if len(records) == 0 {
+		return 0
+	}
+	last := records[len(records)-1]
+	n := 0
+	for n < len(records) && sameKey(records[len(records)-1-n], last, fields) {
+		n++
+	}
+	return n
+}
+
+// loadExportState reads the state of a previous export and checks it was for the same transaction.
+// It returns false when there is no state to resume from.
+func loadExportState(path string, state *exportState) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		logger.Printf("⚠️ No export state found in %s, starting from the beginning", path)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var saved exportState
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return false, fmt.Errorf("failed to parse export state %s: %v", path, err)
+	}
+	if saved.Program != state.Program || saved.Transaction != state.Transaction {
+		return false, fmt.Errorf("export state %s is for %s/%s, not %s/%s", path, saved.Program, saved.Transaction, state.Program, state.Transaction)
+	}
+	if strings.Join(saved.Keys, ",") != strings.Join(state.Keys, ",") {
+		return false, fmt.Errorf("export state %s is for --key %s, not %s", path, strings.Join(saved.Keys, ","), strings.Join(state.Keys, ","))
+	}
+	if !sameParams(saved.Params, state.Params) {
+		return false, fmt.Errorf("export state %s was saved with other --param values (%v), not %v", path, saved.Params, state.Params)
+	}
+	*state = saved
+	return true, nil
+}
+
+// sameParams reports whether two sets of input fields are equal, no fields and an empty set are the same
+func sameParams(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// saveExportState writes the state through a temporary file, so an interruption never leaves a partial state
+func saveExportState(path string, state exportState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// exportWriter streams records as JSON lines or CSV
+type exportWriter struct {
+	columns []column
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+// newExportWriter creates a writer for the output file, CSV if its name ends in .csv. The CSV
+// header is only written for a new file.
+func newExportWriter(w io.Writer, path string, columns []column, header bool) *exportWriter {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		writer := csv.NewWriter(w)
+		if header {
+			writer.Write(columnNames(columns))
+		}
+		return &exportWriter{columns: columns, csv: writer}
+	}
+	return &exportWriter{columns: columns, json: json.NewEncoder(w)}
+}
+
+// write writes one record, numeric fields are written as JSON numbers
+func (w *exportWriter) write(record map[string]string) error {
+	if w.json != nil {
+		return w.json.Encode(typedRecords(w.columns, []map[string]string{record})[0])
+	}
+	values := make([]string, len(w.columns))
+	for i, c := range w.columns {
+		values[i] = record[c.Name]
+	}
+	return w.csv.Write(values)
+}
+
+// flush flushes buffered CSV output
+func (w *exportWriter) flush() error {
+	if w.csv == nil {
+		return nil
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
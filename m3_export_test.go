@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// exportPage builds a page of records with the key field ITNO and a row number
+func exportPage(keys ...string) []map[string]string {
+	records := make([]map[string]string, len(keys))
+	for i, key := range keys {
+		records[i] = map[string]string{"ITNO": key, "ROW": string(rune('a' + i))}
+	}
+	return records
+}
+
+func TestSkipWrittenRecords(t *testing.T) {
+	tests := []struct {
+		name        string
+		page        []map[string]string
+		lastKey     map[string]string
+		lastKeyRows int
+		want        []string
+	}{
+		{"first page", exportPage("A", "B", "C"), nil, 0, []string{"A", "B", "C"}},
+		{"unique key", exportPage("C", "D", "E"), map[string]string{"ITNO": "C"}, 1, []string{"D", "E"}},
+		{"duplicates written", exportPage("C", "C", "D"), map[string]string{"ITNO": "C"}, 2, []string{"D"}},
+		{"duplicates continue", exportPage("C", "C", "C", "D"), map[string]string{"ITNO": "C"}, 2, []string{"C", "D"}},
+		{"last key gone", exportPage("D", "E"), map[string]string{"ITNO": "C"}, 1, []string{"D", "E"}},
+		{"empty page", exportPage(), map[string]string{"ITNO": "C"}, 1, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := exportState{Keys: []string{"ITNO"}, LastKey: tt.lastKey, LastKeyRows: tt.lastKeyRows}
+			got := []string{}
+			for _, record := range skipWrittenRecords(tt.page, state) {
+				got = append(got, record["ITNO"])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("skipWrittenRecords() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrailingKeyRows(t *testing.T) {
+	tests := []struct {
+		name string
+		page []map[string]string
+		want int
+	}{
+		{"empty", exportPage(), 0},
+		{"unique", exportPage("A", "B", "C"), 1},
+		{"trailing duplicates", exportPage("A", "C", "C"), 2},
+		{"leading duplicates", exportPage("A", "A", "C"), 1},
+		{"all the same", exportPage("C", "C", "C"), 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trailingKeyRows(tt.page, []string{"ITNO"}); got != tt.want {
+				t.Errorf("trailingKeyRows() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// exportGateway serves MMS200MI/LstByNumber from the records, sorted by ITNO, starting at the ITNO of
+// the request like M3 does. Requests listed in failures are answered with a 500.
+func exportGateway(t *testing.T, all []map[string]string, failures map[int]bool) string {
+	requests := 0
+	gateway := newTestGateway(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/ACME_TST/M3/m3api-rest/v2/execute/MMS200MI/LstByNumber" {
+			http.NotFound(w, r)
+			return
+		}
+		if failures[requests] {
+			http.Error(w, "gateway timeout", http.StatusInternalServerError)
+			return
+		}
+		query := r.URL.Query()
+		maxRecs, _ := strconv.Atoi(query.Get("maxrecs"))
+		var page []map[string]string
+		for _, record := range all {
+			if record["ITNO"] >= query.Get("ITNO") && len(page) < maxRecs {
+				page = append(page, record)
+			}
+		}
+		writeM3Records(w, "LstByNumber", page)
+	}))
+	return gateway.ionAPIFile
+}
+
+// exportedRows returns the ITNO and ROW of every line of an exported JSON lines file
+func exportedRows(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		var record map[string]string
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line %q of the export is not a JSON record: %v", line, err)
+		}
+		rows = append(rows, record["ITNO"]+record["ROW"])
+	}
+	return rows
+}
+
+func TestExportResume(t *testing.T) {
+	all := exportPage("A", "B", "B", "B", "C", "D", "D", "E")
+	var want []string
+	for _, record := range all {
+		want = append(want, record["ITNO"]+record["ROW"])
+	}
+
+	tests := []struct {
+		name     string
+		failures map[int]bool
+		// runs are the arguments after the transaction, each run but the last fails
+		runs [][]string
+	}{
+		{name: "complete", runs: [][]string{{}}},
+		{name: "resumed after a failed page", failures: map[int]bool{3: true}, runs: [][]string{{}, {"--resume"}}},
+		{name: "resumed twice", failures: map[int]bool{2: true, 4: true}, runs: [][]string{{}, {"--resume"}, {"--resume"}}},
+		{
+			name:     "fresh run after a failed run drops the old state",
+			failures: map[int]bool{3: true, 4: true},
+			runs:     [][]string{{}, {}, {"--resume"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ionAPIFile := exportGateway(t, all, tt.failures)
+			output := filepath.Join(t.TempDir(), "items.jsonl")
+			for i, run := range tt.runs {
+				args := append([]string{ionAPIFile, "MMS200MI/LstByNumber", "--key", "ITNO", "--page-size", "4", "--output", output}, run...)
+				err := runM3Export(args)
+				if last := i == len(tt.runs)-1; last && err != nil {
+					t.Fatalf("run %d failed: %v", i+1, err)
+				} else if !last && err == nil {
+					t.Fatalf("run %d succeeded, the gateway should have failed it", i+1)
+				}
+			}
+			if got := exportedRows(t, output); !reflect.DeepEqual(got, want) {
+				t.Errorf("exported %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestExportResumeRefused(t *testing.T) {
+	all := exportPage("A", "B", "C", "D", "E")
+	tests := []struct {
+		name    string
+		resume  []string
+		prepare func(output string)
+		wantErr string
+	}{
+		{name: "other --param", resume: []string{"--param", "STAT=20"}, wantErr: "other --param values"},
+		{name: "other --key", resume: []string{"--key", "ITNO,FACI"}, wantErr: "not ITNO,FACI"},
+		{name: "truncated output", prepare: func(output string) { os.Truncate(output, 0) }, wantErr: "start the export again"},
+		{name: "missing output", prepare: func(output string) { os.Remove(output) }, wantErr: "cannot resume the export"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ionAPIFile := exportGateway(t, all, map[int]bool{2: true})
+			output := filepath.Join(t.TempDir(), "items.jsonl")
+			args := []string{ionAPIFile, "MMS200MI/LstByNumber", "--key", "ITNO", "--page-size", "2", "--output", output}
+			if err := runM3Export(args); err == nil {
+				t.Fatal("first run succeeded, the gateway should have failed it")
+			}
+			if tt.prepare != nil {
+				tt.prepare(output)
+			}
+			err := runM3Export(append(append(args, "--resume"), tt.resume...))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("resume error = %v, want one containing %q", err, tt.wantErr)
+			}
+			if tt.prepare != nil {
+				if data, _ := ioutil.ReadFile(output); strings.Contains(string(data), "\x00") {
+					t.Error("the output was padded with NUL bytes")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// m3Environment describes the M3 installation and the M3 user of the service account, for support tickets
+type m3Environment struct {
+	Version    string                       `json:"version,omitempty"`
+	User       string                       `json:"user,omitempty"`
+	Company    string                       `json:"company,omitempty"`
+	Division   string                       `json:"division,omitempty"`
+	Facility   string                       `json:"facility,omitempty"`
+	Language   string                       `json:"language,omitempty"`
+	DateFormat string                       `json:"date_format,omitempty"`
+	TimeZone   string                       `json:"time_zone,omitempty"`
+	Raw        map[string]map[string]string `json:"transactions,omitempty"`
+	Errors     []string                     `json:"errors,omitempty"`
+}
+
+// m3UserDataTransactions return the defaults of the M3 user, tried in order
+var m3UserDataTransactions = []string{"MNS150MI/GetUserData", "MNS150MI/GetUserInfo"}
+
+// collectM3Environment reads the M3 version with CMS535MI/FpwVersion and the user defaults with the
+// first user data transaction that answers. Only a failing version call is an error.
+func collectM3Environment(client *m3Client) (*m3Environment, error) {
+	env := &m3Environment{Raw: map[string]map[string]string{}}
+
+	version, err := client.Call("CMS535MI", "FpwVersion", nil, defaultM3Options)
+	if err != nil {
+		return nil, err
+	}
+	if len(version.Records) > 0 {
+		env.Raw["CMS535MI/FpwVersion"] = version.Records[0]
+		env.Version = firstValue(version.Records[0], "VERS", "FPVR", "VRSN", "MVXV")
+	}
+
+	for _, name := range m3UserDataTransactions {
+		program, transaction, _ := parseTransaction(name)
+		result, err := client.Call(program, transaction, nil, defaultM3Options)
+		if err != nil {
+			env.Errors = append(env.Errors, err.Error())
+			continue
+		}
+		if len(result.Records) == 0 {
+			continue
+		}
+		record := result.Records[0]
+		env.Raw[name] = record
+		env.User = firstValue(record, "ZZUSID", "USID", "USER")
+		env.Company = firstValue(record, "ZDCONO", "CONO")
+		env.Division = firstValue(record, "ZDDIVI", "DIVI")
+		env.Facility = firstValue(record, "ZDFACI", "FACI")
+		env.Language = firstValue(record, "ZZLANC", "LANC")
+		env.DateFormat = firstValue(record, "DTFM")
+		env.TimeZone = firstValue(record, "TIZO")
+		env.Errors = nil
+		break
+	}
+	return env, nil
+}
+
+// runM3Info reports the M3 version and user context: m3 info <ionapi-file-path>
+func runM3Info(args []string) error {
+	cli := parseArgs(args, m3ValueFlags...)
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: m3 info <ionapi-file-path> [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	reserveStdout(cli.value("--format", "table"))
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	env, err := collectM3Environment(newM3Client(token, ionAPI.IonBaseURL, ionAPI.TenantID))
+	if err != nil {
+		return err
+	}
+	report.M3Environment = env
+	logger.Printf("✅ M3 version %s, user %s, company %s, division %s", env.Version, env.User, env.Company, env.Division)
+	for _, e := range env.Errors {
+		logger.Printf("⚠️ User data not available: %s", e)
+	}
+
+	// As a table the environment is printed with the report summary
+	if format := cli.value("--format", "table"); format != "table" {
+		records := []map[string]string{}
+		for _, row := range env.rows() {
+			records = append(records, map[string]string{"PROPERTY": row[0], "VALUE": row[1]})
+		}
+		if err := printRecords(os.Stdout, format, []column{{Name: "PROPERTY"}, {Name: "VALUE"}}, records); err != nil {
+			return err
+		}
+	}
+	finishReport()
+	return nil
+}
+
+// rows returns the properties of the environment as name and value pairs, in display order
+func (env *m3Environment) rows() [][2]string {
+	rows := [][2]string{
+		{"Version", env.Version},
+		{"User", env.User},
+		{"Company", env.Company},
+		{"Division", env.Division},
+		{"Facility", env.Facility},
+		{"Language", env.Language},
+		{"Date format", env.DateFormat},
+		{"Time zone", env.TimeZone},
+	}
+	var set [][2]string
+	for _, row := range rows {
+		if strings.TrimSpace(row[1]) != "" {
+			set = append(set, row)
+		}
+	}
+	return set
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"inforconnectiontest/m3api"
+)
+
+// m3InfoHandler answers FpwVersion and the user data transactions in userData
+func m3InfoHandler(userData map[string]map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/ACME_TST/M3/m3api-rest/v2/execute/")
+		if name == "CMS535MI/FpwVersion" {
+			writeM3Records(w, "FpwVersion", []map[string]string{{"VERS": "15.1.3.0", "MVXV": "15.1"}})
+			return
+		}
+		record, ok := userData[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, transaction, _ := parseTransaction(name)
+		writeM3Records(w, transaction, []map[string]string{record})
+	})
+}
+
+func TestCollectM3Environment(t *testing.T) {
+	tests := []struct {
+		name     string
+		userData map[string]map[string]string
+		want     m3Environment
+		errors   int
+	}{
+		{
+			name: "GetUserData",
+			userData: map[string]map[string]string{
+				"MNS150MI/GetUserData": {"ZZUSID": "SVC_ACME", "ZDCONO": "100", "ZDDIVI": "AAA", "ZDFACI": "A01", "ZZLANC": "GB", "DTFM": "YMD", "TIZO": "CET"},
+			},
+			want: m3Environment{Version: "15.1.3.0", User: "SVC_ACME", Company: "100", Division: "AAA", Facility: "A01", Language: "GB", DateFormat: "YMD", TimeZone: "CET"},
+		},
+		{
+			name: "GetUserInfo fallback",
+			userData: map[string]map[string]string{
+				"MNS150MI/GetUserInfo": {"USID": "SVC_ACME", "CONO": "200", "DIVI": "BBB"},
+			},
+			want: m3Environment{Version: "15.1.3.0", User: "SVC_ACME", Company: "200", Division: "BBB"},
+		},
+		{
+			name:   "no user data",
+			want:   m3Environment{Version: "15.1.3.0"},
+			errors: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(m3InfoHandler(tt.userData))
+			defer server.Close()
+
+			env, err := collectM3Environment(m3api.NewClient("test-token", server.URL, "ACME_TST"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(env.Errors) != tt.errors {
+				t.Errorf("errors = %q, want %d", env.Errors, tt.errors)
+			}
+			if len(env.Raw) != len(tt.userData)+1 {
+				t.Errorf("raw transactions = %v", env.Raw)
+			}
+			env.Raw, env.Errors = nil, nil
+			if !reflect.DeepEqual(*env, tt.want) {
+				t.Errorf("collectM3Environment() = %+v, want %+v", *env, tt.want)
+			}
+		})
+	}
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	if _, err := collectM3Environment(m3api.NewClient("test-token", server.URL, "ACME_TST")); err == nil {
+		t.Error("collectM3Environment() succeeded without the M3 version")
+	}
+}
+
+func TestM3EnvironmentRows(t *testing.T) {
+	env := &m3Environment{Version: "15.1.3.0", Company: "100", TimeZone: " "}
+	want := [][2]string{{"Version", "15.1.3.0"}, {"Company", "100"}}
+	if rows := env.rows(); !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows() = %q, want %q", rows, want)
+	}
+}
+
+func TestRunM3Info(t *testing.T) {
+	gateway := newTestGateway(t, m3InfoHandler(map[string]map[string]string{
+		"MNS150MI/GetUserData": {"ZZUSID": "SVC_ACME", "ZDCONO": "100"},
+	}))
+	defer func(saved *m3Environment) { report.M3Environment = saved }(report.M3Environment)
+	defer logger.SetOutput(logger.Writer())
+
+	if err := runM3Info([]string{gateway.ionAPIFile, "--format", "json"}); err != nil {
+		t.Fatalf("runM3Info() failed: %v", err)
+	}
+	if env := report.M3Environment; env == nil || env.User != "SVC_ACME" || env.Company != "100" {
+		t.Errorf("report M3 environment = %+v", report.M3Environment)
+	}
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// m3MetadataPrograms are the MI programs tried in order to list the transactions and fields
+// of a program, the first one that answers is used
+var m3MetadataPrograms = []string{"MRS002MI", "MRS001MI"}
+
+// m3MetadataMaxRecs lifts the default limit of 100 records for the metadata lists
+const m3MetadataMaxRecs = 100000
+
+// m3ProgramInfo describes an MI program
+type m3ProgramInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// m3FieldInfo describes an input or output field of a transaction
+type m3FieldInfo struct {
+	column
+	Mandatory bool `json:"mandatory,omitempty"`
+}
+
+// m3TransactionInfo describes an MI transaction and, once loaded, its fields
+type m3TransactionInfo struct {
+	Name         string        `json:"name"`
+	Description  string        `json:"description"`
+	Inputs       []m3FieldInfo `json:"inputs,omitempty"`
+	Outputs      []m3FieldInfo `json:"outputs,omitempty"`
+	FieldsLoaded bool          `json:"fields_loaded"`
+}
+
+// m3MetadataCache is the metadata cached on disk for a tenant
+type m3MetadataCache struct {
+	UpdatedAt    time.Time                      `json:"updated_at"`
+	Programs     []m3ProgramInfo                `json:"programs,omitempty"`
+	Transactions map[string][]m3TransactionInfo `json:"transactions,omitempty"`
+}
+
+// m3Catalog looks up M3 metadata through MI transactions and caches it on disk per tenant
+type m3Catalog struct {
+	client  *m3Client
+	path    string
+	refresh bool
+	cache   m3MetadataCache
+}
+
+// openM3Catalog loads the metadata cache of the tenant. With refresh set, cached entries are ignored
+// and fetched again.
+func openM3Catalog(client *m3Client, tenantID, cacheDir string, refresh bool) (*m3Catalog, error) {
+	if cacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find a cache directory, use --cache-dir: %v", err)
+		}
+		cacheDir = filepath.Join(userCacheDir, "infor-test")
+	}
+
+	catalog := &m3Catalog{
+		client:  client,
+		path:    filepath.Join(cacheDir, tenantID, "m3-metadata.json"),
+		refresh: refresh,
+		cache:   m3MetadataCache{Transactions: map[string][]m3TransactionInfo{}},
+	}
+	data, err := ioutil.ReadFile(catalog.path)
+	if os.IsNotExist(err) {
+		return catalog, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &catalog.cache); err != nil {
+		logger.Printf("⚠️ Ignoring unreadable metadata cache %s: %v", catalog.path, err)
+		catalog.cache = m3MetadataCache{}
+	}
+	if catalog.cache.Transactions == nil {
+		catalog.cache.Transactions = map[string][]m3TransactionInfo{}
+	}
+	debugPrint("Loaded M3 metadata cache %s (updated %s)", catalog.path, catalog.cache.UpdatedAt.Format(time.RFC3339))
+	return catalog, nil
+}
+
+// save writes the cache back to disk
+func (c *m3Catalog) save() error {
+	c.cache.UpdatedAt = time.Now()
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c.cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, data, 0644)
+}
+
+// programs returns the MI programs of the tenant
+func (c *m3Catalog) programs() ([]m3ProgramInfo, error) {
+	if len(c.cache.Programs) > 0 && !c.refresh {
+		return c.cache.Programs, nil
+	}
+
+	result, err := c.client.Call("MRS001MI", "LstPrograms", nil, m3Options{DateFormat: "YMD8", RightTrim: true, MaxRecs: m3MetadataMaxRecs})
+	if err != nil {
+		return nil, err
+	}
+	var programs []m3ProgramInfo
+	for _, record := range result.Records {
+		programs = append(programs, m3ProgramInfo{Name: record["MINM"], Description: firstValue(record, "MIDS", "TX40", "DESC")})
+	}
+	sort.Slice(programs, func(i, j int) bool { return programs[i].Name < programs[j].Name })
+	c.cache.Programs = programs
+	return programs, c.save()
+}
+
+// transactions returns the transactions of a program, without their fields
+func (c *m3Catalog) transactions(program string) ([]m3TransactionInfo, error) {
+	program = strings.ToUpper(program)
+	if cached, ok := c.cache.Transactions[program]; ok && !c.refresh {
+		return cached, nil
+	}
+
+	result, err := c.callMetadata("LstTransactions", map[string]string{"MINM": program})
+	if err != nil {
+		return nil, err
+	}
+	var transactions []m3TransactionInfo
+	for _, record := range result.Records {
+		transactions = append(transactions, m3TransactionInfo{Name: record["TRNM"], Description: firstValue(record, "TRDS", "TX40", "DESC")})
+	}
+	sort.Slice(transactions, func(i, j int) bool { return transactions[i].Name < transactions[j].Name })
+	c.cache.Transactions[program] = transactions
+	return transactions, c.save()
+}
+
+// transaction returns a transaction of a program with its input and output fields
+func (c *m3Catalog) transaction(program, name string) (*m3TransactionInfo, error) {
+	program = strings.ToUpper(program)
+	transactions, err := c.transactions(program)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range transactions {
+		info := &transactions[i]
+		if !strings.EqualFold(info.Name, name) {
+			continue
+		}
+		if info.FieldsLoaded && !c.refresh {
+			return info, nil
+		}
+		if info.Inputs, err = c.fields(program, info.Name, "I"); err != nil {
+			return nil, err
+		}
+		if info.Outputs, err = c.fields(program, info.Name, "O"); err != nil {
+			return nil, err
+		}
+		info.FieldsLoaded = true
+		return info, c.save()
+	}
+	return nil, fmt.Errorf("transaction %s not found in program %s", name, program)
+}
+
+// fields lists the input (I) or output (O) fields of a transaction, in record order
+func (c *m3Catalog) fields(program, transaction, direction string) ([]m3FieldInfo, error) {
+	result, err := c.callMetadata("LstFields", map[string]string{"MINM": program, "TRNM": transaction, "TRTP": direction})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]m3FieldInfo, len(result.Records))
+	positions := make([]int, len(result.Records))
+	for i, record := range result.Records {
+		length, _ := strconv.Atoi(strings.TrimSpace(record["LENG"]))
+		fields[i] = m3FieldInfo{
+			column: column{
+				Name:        record["FLNM"],
+				Type:        record["TYPE"],
+				Length:      length,
+				Description: firstValue(record, "FLDS", "TX40", "DESC"),
+			},
+			Mandatory: record["MAND"] == "1",
+		}
+		// Fields are put in record order when the from position is available
+		if positions[i], err = strconv.Atoi(strings.TrimSpace(record["FRPO"])); err != nil {
+			positions[i] = i
+		}
+	}
+	sort.Stable(fieldsByPosition{fields, positions})
+	return fields, nil
+}
+
+// callMetadata calls a metadata transaction on the first of m3MetadataPrograms that answers it
+func (c *m3Catalog) callMetadata(transaction string, params map[string]string) (*m3Result, error) {
+	var lastErr error
+	for _, program := range m3MetadataPrograms {
+		result, err := c.client.Call(program, transaction, params, m3Options{DateFormat: "YMD8", RightTrim: true, MaxRecs: m3MetadataMaxRecs})
+		if err == nil {
+			return result, nil
+		}
+		debugPrint("⚠️ %s/%s is not available for metadata: %v", program, transaction, err)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// fieldsByPosition sorts fields by their from position in the record
+type fieldsByPosition struct {
+	fields    []m3FieldInfo
+	positions []int
+}
+
+func (f fieldsByPosition) Len() int           { return len(f.fields) }
+func (f fieldsByPosition) Less(i, j int) bool { return f.positions[i] < f.positions[j] }
+func (f fieldsByPosition) Swap(i, j int) {
+	f.fields[i], f.fields[j] = f.fields[j], f.fields[i]
+	f.positions[i], f.positions[j] = f.positions[j], f.positions[i]
+}
+
+// firstValue returns the first non-empty value among the given fields of a record
+func firstValue(record map[string]string, names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(record[name]); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// m3CatalogFromArgs authenticates and opens the metadata catalog of the tenant
+func m3CatalogFromArgs(cli cliArgs) (*m3Catalog, error) {
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return nil, err
+	}
+	return openM3Catalog(newM3Client(token, ionAPI.IonBaseURL, ionAPI.TenantID), ionAPI.TenantID, cli.value("--cache-dir", ""), cli.has("--refresh"))
+}
+
+// runM3Programs lists the MI programs: m3 programs <ionapi-file-path> [--filter <text>]
+func runM3Programs(args []string) error {
+	cli := parseArgs(args, append(m3ValueFlags, "--filter", "--cache-dir")...)
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: m3 programs <ionapi-file-path> [--filter <text>] [--refresh] [--cache-dir <dir>] [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	reserveStdout(cli.value("--format", "table"))
+
+	catalog, err := m3CatalogFromArgs(cli)
+	if err != nil {
+		return err
+	}
+	programs, err := catalog.programs()
+	if err != nil {
+		return err
+	}
+
+	filter := strings.ToUpper(cli.value("--filter", ""))
+	var records []map[string]string
+	for _, program := range programs {
+		if filter != "" && !strings.Contains(strings.ToUpper(program.Name+" "+program.Description), filter) {
+			continue
+		}
+		records = append(records, map[string]string{"PROGRAM": program.Name, "DESCRIPTION": program.Description})
+	}
+	logger.Printf("✅ %d MI program(s) found", len(records))
+
+	columns := []column{{Name: "PROGRAM"}, {Name: "DESCRIPTION"}}
+	if err := printRecords(os.Stdout, cli.value("--format", "table"), columns, records); err != nil {
+		return err
+	}
+	finishReport()
+	return nil
+}
+
+// runM3Transactions lists the transactions of a program: m3 transactions <ionapi-file-path> <PROGRAM>
+func runM3Transactions(args []string) error {
+	cli := parseArgs(args, append(m3ValueFlags, "--cache-dir")...)
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: m3 transactions <ionapi-file-path> <PROGRAM> [--refresh] [--cache-dir <dir>] [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	reserveStdout(cli.value("--format", "table"))
+
+	catalog, err := m3CatalogFromArgs(cli)
+	if err != nil {
+		return err
+	}
+	program := strings.ToUpper(cli.positional[1])
+	transactions, err := catalog.transactions(program)
+	if err != nil {
+		return err
+	}
+	logger.Printf("✅ %s has %d transaction(s)", program, len(transactions))
+
+	var records []map[string]string
+	for _, transaction := range transactions {
+		records = append(records, map[string]string{"TRANSACTION": transaction.Name, "DESCRIPTION": transaction.Description})
+	}
+	columns := []column{{Name: "TRANSACTION"}, {Name: "DESCRIPTION"}}
+	if err := printRecords(os.Stdout, cli.value("--format", "table"), columns, records); err != nil {
+		return err
+	}
+	finishReport()
+	return nil
+}
+
+// runM3Fields lists the input and output fields of a transaction: m3 fields <ionapi-file-path> <PROGRAM>/<TRANSACTION>
+func runM3Fields(args []string) error {
+	cli := parseArgs(args, append(m3ValueFlags, "--cache-dir")...)
+	if len(cli.positional) < 2 {
+		return fmt.Errorf("usage: m3 fields <ionapi-file-path> <PROGRAM>/<TRANSACTION> [--refresh] [--cache-dir <dir>] [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	reserveStdout(cli.value("--format", "table"))
+
+	program, name, err := parseTransaction(cli.positional[1])
+	if err != nil {
+		return err
+	}
+	catalog, err := m3CatalogFromArgs(cli)
+	if err != nil {
+		return err
+	}
+	transaction, err := catalog.transaction(program, name)
+	if err != nil {
+		return err
+	}
+	logger.Printf("✅ %s/%s has %d input and %d output field(s)", program, transaction.Name, len(transaction.Inputs), len(transaction.Outputs))
+
+	var records []map[string]string
+	for _, fields := range []struct {
+		direction string
+		fields    []m3FieldInfo
+	}{{"input", transaction.Inputs}, {"output", transaction.Outputs}} {
+		for _, field := range fields.fields {
+			mandatory := ""
+			if field.Mandatory {
+				mandatory = "yes"
+			}
+			records = append(records, map[string]string{
+				"DIRECTION":   fields.direction,
+				"FIELD":       field.Name,
+				"TYPE":        field.Type,
+				"LENGTH":      strconv.Itoa(field.Length),
+				"MANDATORY":   mandatory,
+				"DESCRIPTION": field.Description,
+			})
+		}
+	}
+	columns := []column{{Name: "DIRECTION"}, {Name: "FIELD"}, {Name: "TYPE"}, {Name: "LENGTH"}, {Name: "MANDATORY"}, {Name: "DESCRIPTION"}}
+	if err := printRecords(os.Stdout, cli.value("--format", "table"), columns, records); err != nil {
+		return err
+	}
+	finishReport()
+	return nil
+}
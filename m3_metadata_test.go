@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"inforconnectiontest/m3api"
+)
+
+// metadataServer answers the MRS001MI metadata transactions for MMS200MI, MRS002MI is not available
+func metadataServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		query := r.URL.Query()
+		switch strings.TrimPrefix(r.URL.Path, "/ACME_TST/M3/m3api-rest/v2/execute/") {
+		case "MRS001MI/LstPrograms":
+			writeM3Records(w, "LstPrograms", []map[string]string{
+				{"MINM": "MMS200MI", "MIDS": "Item interface"},
+				{"MINM": "CRS610MI", "TX40": "Customer interface"},
+			})
+		case "MRS001MI/LstTransactions":
+			if query.Get("MINM") != "MMS200MI" {
+				writeM3Records(w, "LstTransactions", nil)
+				return
+			}
+			writeM3Records(w, "LstTransactions", []map[string]string{
+				{"TRNM": "LstItmByItm", "TRDS": "List items"},
+				{"TRNM": "GetItmBasic", "TRDS": "Get item basic data"},
+			})
+		case "MRS001MI/LstFields":
+			if query.Get("TRTP") == "I" {
+				writeM3Records(w, "LstFields", []map[string]string{
+					{"FLNM": "ITNO", "TYPE": "A", "LENG": "15", "MAND": "1", "FRPO": "4", "FLDS": "Item number"},
+					{"FLNM": "CONO", "TYPE": "N", "LENG": " 3", "MAND": "0", "FRPO": "1", "FLDS": "Company"},
+				})
+				return
+			}
+			writeM3Records(w, "LstFields", []map[string]string{
+				{"FLNM": "ITNO", "TYPE": "A", "LENG": "15", "FRPO": "1"},
+				{"FLNM": "GRWE", "TYPE": "N", "LENG": "9", "FRPO": "16"},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestM3Catalog(t *testing.T) {
+	var calls int32
+	server := metadataServer(&calls)
+	defer server.Close()
+	client := m3api.NewClient("test-token", server.URL, "ACME_TST")
+	cacheDir := t.TempDir()
+
+	catalog, err := openM3Catalog(client, "ACME_TST", cacheDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	programs, err := catalog.programs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPrograms := []m3ProgramInfo{{Name: "CRS610MI", Description: "Customer interface"}, {Name: "MMS200MI", Description: "Item interface"}}
+	if !reflect.DeepEqual(programs, wantPrograms) {
+		t.Errorf("programs() = %+v, want %+v", programs, wantPrograms)
+	}
+
+	transaction, err := catalog.transaction("mms200mi", "getitmbasic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInputs := []m3FieldInfo{
+		{column: column{Name: "CONO", Type: "N", Length: 3, Description: "Company"}},
+		{column: column{Name: "ITNO", Type: "A", Length: 15, Description: "Item number"}, Mandatory: true},
+	}
+	if transaction.Name != "GetItmBasic" || !reflect.DeepEqual(transaction.Inputs, wantInputs) || len(transaction.Outputs) != 2 {
+		t.Errorf("transaction() = %+v", transaction)
+	}
+	if _, err := catalog.transaction("MMS200MI", "AddItmBasic"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("transaction() of an unknown transaction error = %v", err)
+	}
+
+	// A new catalog reads everything from the cache, --refresh fetches it again
+	atomic.StoreInt32(&calls, 0)
+	cached, err := openM3Catalog(client, "ACME_TST", cacheDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.programs(); err != nil {
+		t.Fatal(err)
+	}
+	if transaction, err := cached.transaction("MMS200MI", "GetItmBasic"); err != nil || !reflect.DeepEqual(transaction.Inputs, wantInputs) {
+		t.Errorf("cached transaction() = %+v, %v", transaction, err)
+	}
+	if got := atomic.LoadInt32(&calls); got != 0 {
+		t.Errorf("%d call(s) with a filled cache, want none", got)
+	}
+
+	refreshed, err := openM3Catalog(client, "ACME_TST", cacheDir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refreshed.transactions("MMS200MI"); err != nil {
+		t.Fatal(err)
+	}
+	// MRS002MI is tried before falling back to MRS001MI
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("%d call(s) to refresh the transactions, want 2", got)
+	}
+}
+
+func TestFirstValue(t *testing.T) {
+	record := map[string]string{"MIDS": "  ", "TX40": " Item interface ", "DESC": "other"}
+	if got := firstValue(record, "MIDS", "TX40", "DESC"); got != "Item interface" {
+		t.Errorf("firstValue() = %q", got)
+	}
+	if got := firstValue(record, "TX15"); got != "" {
+		t.Errorf("firstValue() of a missing field = %q", got)
+	}
+}
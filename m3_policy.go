@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// m3Policy decides which MI transactions may run. Write transactions on production tenants are
+// blocked unless they are allowed or confirmed interactively, and every write is audited.
+type m3Policy struct {
+	// ProductionTenants are glob patterns matched against the tenant ID, case insensitive
+	ProductionTenants []string `json:"production_tenants"`
+	// WritePrefixes are the transaction name prefixes that change data
+	WritePrefixes []string `json:"write_prefixes"`
+	// Allow lists the write transactions allowed on production, as PROGRAM/TRANSACTION or PROGRAM/*
+	Allow []string `json:"allow"`
+	// AuditLog is the JSON lines file every write is recorded in
+	AuditLog string `json:"audit_log"`
+
+	mu        sync.Mutex
+	confirmed map[string]bool
+}
+
+// defaultM3Policy is used when no --policy file is given
+var defaultM3Policy = m3Policy{
+	ProductionTenants: []string{"*_PRD", "*_PROD"},
+	WritePrefixes:     []string{"Add", "Upd", "Dlt", "Del", "Chg", "Crt", "Cpy", "Set", "Rel", "Cls", "Cnf", "Prc", "Rpt", "Mnt", "Imp", "Cancel", "Cnl", "Delete", "Update", "Create", "Change"},
+	AuditLog:          "m3-audit.jsonl",
+}
+
+// policy is the policy applied by every m3Client
+var policy = &defaultM3Policy
+
+// m3AuditEntry records a write transaction
+type m3AuditEntry struct {
+	Time        time.Time         `json:"time"`
+	User        string            `json:"user"`
+	Tenant      string            `json:"tenant"`
+	Production  bool              `json:"production"`
+	Program     string            `json:"program"`
+	Transaction string            `json:"transaction"`
+	Record      map[string]string `json:"record,omitempty"`
+	Decision    string            `json:"decision"`
+	Outcome     string            `json:"outcome"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// Decisions of the policy for a write transaction
+const (
+	decisionNonProduction = "non-production tenant"
+	decisionAllowlisted   = "allowlisted"
+	decisionConfirmed     = "confirmed interactively"
+	decisionBlocked       = "blocked"
+)
+
+// configureM3Policy loads the policy from --policy and adds the transactions of --allow-write
+func configureM3Policy(cli cliArgs) error {
+	if file := cli.value("--policy", ""); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		loaded := &m3Policy{}
+		if err := json.Unmarshal(data, loaded); err != nil {
+			return fmt.Errorf("failed to parse policy file %s: %v", file, err)
+		}
+		if loaded.ProductionTenants == nil {
+			loaded.ProductionTenants = defaultM3Policy.ProductionTenants
+		}
+		if loaded.WritePrefixes == nil {
+			loaded.WritePrefixes = defaultM3Policy.WritePrefixes
+		}
+		if loaded.AuditLog == "" {
+			loaded.AuditLog = defaultM3Policy.AuditLog
+		}
+		policy = loaded
+	}
+	policy.Allow = append(policy.Allow, cli.values("--allow-write")...)
+	return nil
+}
+
+// isWrite reports whether the transaction changes data, judged by the prefix of its name
+func (p *m3Policy) isWrite(transaction string) bool {
+	for _, prefix := range p.WritePrefixes {
+		if len(transaction) < len(prefix) || !strings.EqualFold(transaction[:len(prefix)], prefix) {
+			continue
+		}
+		// Prefixes end where the next word starts with a capital letter, so Address is not Add. Names
+		// in a single case have no word boundaries and count as writes.
+		if len(transaction) == len(prefix) || transaction == strings.ToLower(transaction) || transaction == strings.ToUpper(transaction) {
+			return true
+		}
+		if next := transaction[len(prefix)]; next >= 'A' && next <= 'Z' || next >= '0' && next <= '9' {
+			return true
+		}
+	}
+	return false
+}
+
+// production reports whether the tenant matches one of the production patterns
+func (p *m3Policy) production(tenantID string) bool {
+	for _, pattern := range p.ProductionTenants {
+		if matched, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(tenantID)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed reports whether the transaction is on the allowlist
+func (p *m3Policy) allowed(program, transaction string) bool {
+	for _, entry := range p.Allow {
+		allowedProgram, allowedTransaction, _ := strings.Cut(entry, "/")
+		if strings.EqualFold(allowedProgram, program) && (allowedTransaction == "*" || strings.EqualFold(allowedTransaction, transaction)) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorize decides whether a write transaction may run on the tenant. Write transactions on
+// production tenants need to be allowlisted or confirmed at the terminal, once per transaction.
+func (p *m3Policy) authorize(tenantID, program, transaction string) (string, error) {
+	if !p.production(tenantID) {
+		return decisionNonProduction, nil
+	}
+	if p.allowed(program, transaction) {
+		return decisionAllowlisted, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := strings.ToUpper(program + "/" + transaction)
+	if p.confirmed[key] {
+		return decisionConfirmed, nil
+	}
+	blocked := fmt.Errorf("%s/%s changes data on production tenant %s and is blocked, allow it with --allow-write %s/%s",
+		program, transaction, tenantID, program, transaction)
+	if !interactive() {
+		return decisionBlocked, blocked
+	}
+
+	fmt.Fprintf(os.Stderr, "⚠️ %s/%s changes data on production tenant %s. Type the tenant ID to continue: ", program, transaction, tenantID)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(os.Stderr)
+		return decisionBlocked, blocked
+	}
+	if strings.TrimSpace(answer) != tenantID {
+		return decisionBlocked, fmt.Errorf("%s/%s on production tenant %s was not confirmed", program, transaction, tenantID)
+	}
+	if p.confirmed == nil {
+		p.confirmed = map[string]bool{}
+	}
+	p.confirmed[key] = true
+	return decisionConfirmed, nil
+}
+
+// audit appends an entry for a write transaction to the audit log
+func (p *m3Policy) audit(entry m3AuditEntry) {
+	entry.Time = time.Now()
+	entry.User = currentUser()
+	if entry.Outcome == "" {
+		entry.Outcome = "ok"
+		if entry.Error != "" {
+			entry.Outcome = "failed"
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	file, err := os.OpenFile(p.AuditLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Printf("⚠️ Failed to write audit log %s: %v", p.AuditLog, err)
+		return
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(entry); err != nil {
+		logger.Printf("⚠️ Failed to write audit log %s: %v", p.AuditLog, err)
+	}
+}
+
+// interactive reports whether stdin is a terminal a confirmation can be read from
+func interactive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// currentUser returns the name of the user running the tool, for the audit log
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// guardM3Write authorizes a write transaction of the tenant, recording blocked calls in the audit log.
+// It returns an empty decision for read transactions.
+func guardM3Write(tenantID, program, transaction string, record map[string]string) (string, error) {
+	if !policy.isWrite(transaction) {
+		return "", nil
+	}
+	decision, err := policy.authorize(tenantID, program, transaction)
+	if err != nil {
+		policy.audit(m3AuditEntry{Tenant: tenantID, Production: policy.production(tenantID), Program: program,
+			Transaction: transaction, Record: record, Decision: decision, Outcome: decisionBlocked, Error: err.Error()})
+		return decision, err
+	}
+	return decision, nil
+}
+
+// auditM3Write records the outcome of a write transaction that was allowed by guardM3Write
+func auditM3Write(tenantID, decision, program, transaction string, record map[string]string, err error) {
+	if decision == "" {
+		return
+	}
+	entry := m3AuditEntry{Tenant: tenantID, Production: policy.production(tenantID), Program: program,
+		Transaction: transaction, Record: record, Decision: decision}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	policy.audit(entry)
+}
@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestM3PolicyIsWrite(t *testing.T) {
+	tests := []struct {
+		transaction string
+		want        bool
+	}{
+		{"AddItmBasic", true},
+		{"UpdBasicData", true},
+		{"DltLine", true},
+		{"Add", true},
+		{"addItmBasic", true},
+		{"additmbasic", true},
+		{"ADDITMBASIC", true},
+		{"Add2Item", true},
+		{"Address", false},
+		{"Updated", false},
+		{"Settle", false},
+		{"GetBasicData", false},
+		{"LstByNumber", false},
+		{"FpwVersion", false},
+		{"Ad", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := defaultM3Policy.isWrite(tt.transaction); got != tt.want {
+			t.Errorf("isWrite(%q) = %v, want %v", tt.transaction, got, tt.want)
+		}
+	}
+}
+
+func TestM3PolicyProduction(t *testing.T) {
+	tests := []struct {
+		tenantID string
+		want     bool
+	}{
+		{"ACME_PRD", true},
+		{"acme_prd", true},
+		{"ACME_PROD", true},
+		{"ACME_TST", false},
+		{"ACME_PRD_TST", false},
+		{"PRD", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := defaultM3Policy.production(tt.tenantID); got != tt.want {
+			t.Errorf("production(%q) = %v, want %v", tt.tenantID, got, tt.want)
+		}
+	}
+}
+
+func TestM3PolicyAllowed(t *testing.T) {
+	p := &m3Policy{Allow: []string{"MMS200MI/AddItmBasic", "CRS610MI/*"}}
+	tests := []struct {
+		program     string
+		transaction string
+		want        bool
+	}{
+		{"MMS200MI", "AddItmBasic", true},
+		{"mms200mi", "additmbasic", true},
+		{"MMS200MI", "UpdItmBasic", false},
+		{"CRS610MI", "UpdBasicData", true},
+		{"CRS610MI", "DltCustomer", true},
+		{"OIS100MI", "AddHead", false},
+		{"MMS200", "AddItmBasic", false},
+	}
+	for _, tt := range tests {
+		if got := p.allowed(tt.program, tt.transaction); got != tt.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", tt.program, tt.transaction, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Package m3api calls M3 MI transactions through the m3api-rest v2 endpoint of the ION API Gateway.
+// It is the M3 client of Infor-test and the package imported by the code that m3 codegen generates.
+package m3api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Options are the query options of the m3api-rest v2 execute endpoint
+type Options struct {
+	DateFormat   string
+	ExcludeEmpty bool
+	RightTrim    bool
+	MaxRecs      int
+	ReturnCols   []string
+	Company      string
+	Division     string
+}
+
+// DefaultOptions return dates as YYYYMMDD and trim trailing blanks
+var DefaultOptions = Options{DateFormat: "YMD8", RightTrim: true}
+
+// Client calls M3 MI transactions through the ION API Gateway. The hooks are optional.
+type Client struct {
+	BaseURL    string
+	TenantID   string
+	Token      string
+	HTTPClient *http.Client
+
+	// Trace is called before a request is sent, done is called with the transport error or nil
+	Trace func(req *http.Request, label string) (traced *http.Request, done func(err error))
+	// Debug receives the raw response bodies
+	Debug func(format string, v ...interface{})
+	// Guard is called before a transaction is sent, an error blocks it. The decision is passed to Audit.
+	Guard func(program, transaction string, record map[string]string) (decision string, err error)
+	// Audit is called with the outcome of every transaction that passed Guard
+	Audit func(decision, program, transaction string, record map[string]string, err error)
+}
+
+// Response is the response of the execute endpoint
+type Response struct {
+	Results              []Result `json:"results"`
+	WasTerminated        bool     `json:"wasTerminated"`
+	TerminationReason    string   `json:"terminationReason"`
+	TerminationErrorType string   `json:"terminationErrorType"`
+	NrOfSuccessful       int      `json:"nrOfSuccessfullTransactions"`
+	NrOfFailed           int      `json:"nrOfFailedTransactions"`
+	NrOfNotProcessed     int      `json:"nrOfNotProcessedTransactions"`
+}
+
+// Result is the result of a single transaction. M3 reports business errors here with
+// HTTP status 200, in the error fields of the result.
+type Result struct {
+	Transaction  string              `json:"transaction"`
+	Records      []map[string]string `json:"records"`
+	Metadata     Metadata            `json:"metadata"`
+	ErrorMessage string              `json:"errorMessage"`
+	ErrorType    string              `json:"errorType"`
+	ErrorCode    string              `json:"errorCode"`
+	ErrorField   string              `json:"errorField"`
+}
+
+// Metadata describes the output fields of a transaction
+type Metadata struct {
+	Fields []Field `json:"field"`
+}
+
+// Field is an output field of a transaction, M3 uses type N for numeric fields
+type Field struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Length      int    `json:"length,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Error is a business error returned by an MI transaction
+type Error struct {
+	Program     string
+	Transaction string
+	Message     string
+	Type        string
+	MessageID   string
+	Field       string
+}
+
+// Error formats the error with its message ID and field, as shown in M3
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s/%s failed", e.Program, e.Transaction)
+	if e.MessageID != "" {
+		msg += fmt.Sprintf(" [%s]", e.MessageID)
+	}
+	msg += ": " + e.Message
+	if e.Field != "" {
+		msg += fmt.Sprintf(" (field %s)", e.Field)
+	}
+	if e.Type != "" {
+		msg += fmt.Sprintf(" (%s)", e.Type)
+	}
+	return msg
+}
+
+// ParseResponse parses the body of an execute response
+func ParseResponse(body []byte) (*Response, error) {
+	var response Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse M3 response: %v", err)
+	}
+	return &response, nil
+}
+
+// Failed reports whether M3 returned a business error for this result
+func (r *Result) Failed() bool {
+	return r.ErrorMessage != "" || r.ErrorCode != "" || r.ErrorType != ""
+}
+
+// Err returns the business error of the result as an *Error, or nil
+func (r *Result) Err(program string) error {
+	if !r.Failed() {
+		return nil
+	}
+	message := strings.TrimSpace(r.ErrorMessage)
+	if message == "" {
+		message = "transaction returned an error without a message"
+	}
+	return &Error{Program: program, Transaction: r.Transaction, Message: message, Type: r.ErrorType, MessageID: r.ErrorCode, Field: r.ErrorField}
+}
+
+// NewClient creates a client for the M3 REST API of the tenant
+func NewClient(token string, baseURL string, tenantID string) *Client {
+	return &Client{
+		BaseURL:    fmt.Sprintf("%s/%s/M3/m3api-rest/v2", strings.TrimSuffix(baseURL, "/"), tenantID),
+		TenantID:   tenantID,
+		Token:      token,
+		HTTPClient: &http.Client{},
+	}
+}
+
+// ExecuteURL builds the execute URL of a transaction with its input fields and options
+func (c *Client) ExecuteURL(program, transaction string, params map[string]string, opts Options) string {
+	query := url.Values{}
+	query.Set("dateformat", opts.DateFormat)
+	query.Set("excludeempty", strconv.FormatBool(opts.ExcludeEmpty))
+	query.Set("righttrim", strconv.FormatBool(opts.RightTrim))
+	query.Set("format", "PRETTY")
+	query.Set("extendedresult", "false")
+	if opts.MaxRecs > 0 {
+		query.Set("maxrecs", strconv.Itoa(opts.MaxRecs))
+	}
+	if len(opts.ReturnCols) > 0 {
+		query.Set("returncols", strings.Join(opts.ReturnCols, ","))
+	}
+	if opts.Company != "" {
+		query.Set("cono", opts.Company)
+	}
+	if opts.Division != "" {
+		query.Set("divi", opts.Division)
+	}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	return fmt.Sprintf("%s/execute/%s/%s?%s", c.BaseURL, url.PathEscape(program), url.PathEscape(transaction), query.Encode())
+}
+
+// execute calls a transaction and returns the raw response body
+func (c *Client) execute(program, transaction string, params map[string]string, opts Options) ([]byte, error) {
+	req, err := http.NewRequest("GET", c.ExecuteURL(program, transaction, params, opts), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req, fmt.Sprintf("M3 %s/%s", program, transaction))
+}
+
+// Transaction is one transaction of a multi-transaction request
+type Transaction struct {
+	Transaction     string            `json:"transaction"`
+	Record          map[string]string `json:"record,omitempty"`
+	SelectedColumns []string          `json:"selectedColumns,omitempty"`
+}
+
+// MultiRequest is the body of a multi-transaction request, all transactions belong to one program
+type MultiRequest struct {
+	Program            string        `json:"program"`
+	Company            string        `json:"cono,omitempty"`
+	Division           string        `json:"divi,omitempty"`
+	ExcludeEmptyValues bool          `json:"excludeEmptyValues"`
+	RightTrim          bool          `json:"rightTrim"`
+	MaxReturnedRecords int           `json:"maxReturnedRecords,omitempty"`
+	Transactions       []Transaction `json:"transactions"`
+}
+
+// ExecuteMulti sends several transactions of one program in a single request. The results
+// are returned in the order of the transactions. If Guard blocks one transaction, none are sent.
+func (c *Client) ExecuteMulti(program string, transactions []Transaction, opts Options) (*Response, error) {
+	decisions := make([]string, len(transactions))
+	for i := range transactions {
+		if len(transactions[i].SelectedColumns) == 0 {
+			transactions[i].SelectedColumns = opts.ReturnCols
+		}
+		var err error
+		if decisions[i], err = c.guard(program, transactions[i].Transaction, transactions[i].Record); err != nil {
+			return nil, err
+		}
+	}
+	body, err := json.Marshal(MultiRequest{
+		Program:            program,
+		Company:            opts.Company,
+		Division:           opts.Division,
+		ExcludeEmptyValues: opts.ExcludeEmpty,
+		RightTrim:          opts.RightTrim,
+		MaxReturnedRecords: opts.MaxRecs,
+		Transactions:       transactions,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("dateformat", opts.DateFormat)
+	query.Set("format", "PRETTY")
+	query.Set("extendedresult", "false")
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/execute?%s", c.BaseURL, query.Encode()), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	respBody, err := c.do(req, fmt.Sprintf("M3 %s multi (%d transactions)", program, len(transactions)))
+	var response *Response
+	if err == nil {
+		response, err = ParseResponse(respBody)
+	}
+	for i, transaction := range transactions {
+		resultErr := err
+		if err == nil && i < len(response.Results) {
+			resultErr = response.Results[i].Err(program)
+		} else if err == nil {
+			resultErr = fmt.Errorf("not processed")
+		}
+		c.audit(decisions[i], program, transaction.Transaction, transaction.Record, resultErr)
+	}
+	return response, err
+}
+
+// do sends an authenticated request to the M3 API and returns the body of a 200 response
+func (c *Client) do(req *http.Request, label string) ([]byte, error) {
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	req.Header.Set("accept", "application/json")
+	done := func(error) {}
+	if c.Trace != nil {
+		req, done = c.Trace(req, label)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		done(err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		c.debug("M3 API Response: %s", body)
+		return nil, fmt.Errorf("API request failed with status: %s", resp.Status)
+	}
+	return body, nil
+}
+
+// Call executes a transaction and parses its result. M3 business errors are returned as *Error,
+// together with the result. Transactions are checked with Guard and passed to Audit.
+func (c *Client) Call(program, transaction string, params map[string]string, opts Options) (*Result, error) {
+	decision, err := c.guard(program, transaction, params)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.call(program, transaction, params, opts)
+	c.audit(decision, program, transaction, params, err)
+	return result, err
+}
+
+// call executes a transaction without Guard and Audit
+func (c *Client) call(program, transaction string, params map[string]string, opts Options) (*Result, error) {
+	body, err := c.execute(program, transaction, params, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.debug("M3 API Response: %s", body)
+
+	response, err := ParseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Results) == 0 {
+		if response.WasTerminated {
+			return nil, fmt.Errorf("%s/%s was terminated: %s %s", program, transaction, response.TerminationErrorType, response.TerminationReason)
+		}
+		return nil, fmt.Errorf("M3 response contains no results")
+	}
+
+	result := &response.Results[0]
+	if result.Transaction == "" {
+		result.Transaction = transaction
+	}
+	return result, result.Err(program)
+}
+
+// guard calls the Guard hook, if any
+func (c *Client) guard(program, transaction string, record map[string]string) (string, error) {
+	if c.Guard == nil {
+		return "", nil
+	}
+	return c.Guard(program, transaction, record)
+}
+
+// audit calls the Audit hook, if any
+func (c *Client) audit(decision, program, transaction string, record map[string]string, err error) {
+	if c.Audit != nil {
+		c.Audit(decision, program, transaction, record, err)
+	}
+}
+
+// debug calls the Debug hook, if any
+func (c *Client) debug(format string, v ...interface{}) {
+	if c.Debug != nil {
+		c.Debug(format, v...)
+	}
+}
+
+// Number parses a numeric field of a record, empty or invalid values are returned as 0
+func Number(value string) float64 {
+	n, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return n
+}
+
+// FormatNumber formats a numeric input field without trailing zeros
+func FormatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
@@ -0,0 +1,184 @@
+package m3api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  Error
+		want string
+	}{
+		{
+			err:  Error{Program: "MMS200MI", Transaction: "GetItmBasic", Message: "Item number A1 does not exist", MessageID: "WITNO03", Field: "ITNO", Type: "ServerReturnedNOK"},
+			want: "MMS200MI/GetItmBasic failed [WITNO03]: Item number A1 does not exist (field ITNO) (ServerReturnedNOK)",
+		},
+		{
+			err:  Error{Program: "CRS610MI", Transaction: "GetBasicData", Message: "Customer does not exist"},
+			want: "CRS610MI/GetBasicData failed: Customer does not exist",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestResultErr(t *testing.T) {
+	if err := (&Result{Transaction: "GetItmBasic"}).Err("MMS200MI"); err != nil {
+		t.Errorf("Err() of a successful result = %v", err)
+	}
+
+	result := &Result{Transaction: "GetItmBasic", ErrorType: "ServerReturnedNOK"}
+	var m3Err *Error
+	if err := result.Err("MMS200MI"); !errors.As(err, &m3Err) || m3Err.Message != "transaction returned an error without a message" {
+		t.Errorf("Err() of an error without a message = %v", err)
+	}
+
+	result = &Result{Transaction: "GetItmBasic", ErrorMessage: "  Item does not exist  ", ErrorCode: "WITNO03", ErrorField: "ITNO"}
+	if !result.Failed() {
+		t.Fatal("Failed() = false for a result with an error message")
+	}
+	if err := result.Err("MMS200MI"); !errors.As(err, &m3Err) || m3Err.Message != "Item does not exist" || m3Err.MessageID != "WITNO03" || m3Err.Field != "ITNO" {
+		t.Errorf("Err() = %#v", err)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	response, err := ParseResponse([]byte(`{
+		"results": [{
+			"transaction": "GetItmBasic",
+			"records": [{"ITNO": "A1", "ITDS": "Bolt", "NEWE": "1.5"}],
+			"metadata": {"field": [{"name": "ITNO", "type": "A", "length": 15, "description": "Item number"}, {"name": "NEWE", "type": "N", "length": 9}]}
+		}],
+		"nrOfSuccessfullTransactions": 1
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.NrOfSuccessful != 1 || len(response.Results) != 1 {
+		t.Fatalf("ParseResponse() = %+v", response)
+	}
+	result := response.Results[0]
+	if result.Records[0]["ITDS"] != "Bolt" || len(result.Metadata.Fields) != 2 || result.Metadata.Fields[1] != (Field{Name: "NEWE", Type: "N", Length: 9}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	if _, err := ParseResponse([]byte(`<html>Bad gateway</html>`)); err == nil {
+		t.Error("ParseResponse() accepted an HTML page")
+	}
+}
+
+func TestExecuteURL(t *testing.T) {
+	client := NewClient("token", "https://mingle-ionapi.inforcloudsuite.com/", "ACME_TST")
+	opts := DefaultOptions
+	opts.MaxRecs = 10
+	opts.ReturnCols = []string{"ITNO", "ITDS"}
+	opts.Company = "100"
+
+	rawURL := client.ExecuteURL("MMS200MI", "LstItmByItm", map[string]string{"ITNO": "A 1"}, opts)
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsedURL.Path != "/ACME_TST/M3/m3api-rest/v2/execute/MMS200MI/LstItmByItm" {
+		t.Errorf("path = %s", parsedURL.Path)
+	}
+	want := map[string]string{"dateformat": "YMD8", "righttrim": "true", "excludeempty": "false", "maxrecs": "10",
+		"returncols": "ITNO,ITDS", "cono": "100", "ITNO": "A 1"}
+	query := parsedURL.Query()
+	for key, value := range want {
+		if query.Get(key) != value {
+			t.Errorf("query %s = %q, want %q", key, query.Get(key), value)
+		}
+	}
+	if query.Has("divi") {
+		t.Error("query has divi without a division")
+	}
+}
+
+func TestCall(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch strings.TrimPrefix(r.URL.Path, "/ACME_TST/M3/m3api-rest/v2/execute/") {
+		case "MMS200MI/GetItmBasic":
+			w.Write([]byte(`{"results": [{"records": [{"ITNO": "A1"}]}]}`))
+		case "MMS200MI/GetItmWhsBasic":
+			w.Write([]byte(`{"results": [{"transaction": "GetItmWhsBasic", "errorMessage": "Warehouse does not exist", "errorCode": "WWH0103"}]}`))
+		case "CRS610MI/LstByNumber":
+			w.Write([]byte(`{"results": [], "wasTerminated": true, "terminationErrorType": "ServerReturnedNOK", "terminationReason": "Timeout"}`))
+		case "CRS610MI/GetBasicData":
+			w.Write([]byte(`{"results": []}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("token", server.URL, "ACME_TST")
+	var audited []string
+	client.Guard = func(program, transaction string, record map[string]string) (string, error) {
+		if strings.HasPrefix(transaction, "Add") {
+			return "", errors.New("blocked")
+		}
+		return "allowed", nil
+	}
+	client.Audit = func(decision, program, transaction string, record map[string]string, err error) {
+		audited = append(audited, decision+" "+program+"/"+transaction)
+	}
+
+	result, err := client.Call("MMS200MI", "GetItmBasic", nil, DefaultOptions)
+	if err != nil || result.Transaction != "GetItmBasic" || result.Records[0]["ITNO"] != "A1" {
+		t.Errorf("Call(GetItmBasic) = %+v, %v", result, err)
+	}
+
+	result, err = client.Call("MMS200MI", "GetItmWhsBasic", nil, DefaultOptions)
+	var m3Err *Error
+	if !errors.As(err, &m3Err) || m3Err.MessageID != "WWH0103" || result == nil {
+		t.Errorf("Call(GetItmWhsBasic) = %+v, %v, want the M3 error with the result", result, err)
+	}
+
+	tests := []struct {
+		program, transaction string
+		wantErr              string
+	}{
+		{"CRS610MI", "LstByNumber", "CRS610MI/LstByNumber was terminated: ServerReturnedNOK Timeout"},
+		{"CRS610MI", "GetBasicData", "M3 response contains no results"},
+		{"CRS610MI", "GetNothing", "API request failed with status: 404 Not Found"},
+		{"MMS200MI", "AddItmBasic", "blocked"},
+	}
+	for _, tt := range tests {
+		if _, err := client.Call(tt.program, tt.transaction, nil, DefaultOptions); err == nil || err.Error() != tt.wantErr {
+			t.Errorf("Call(%s/%s) error = %v, want %q", tt.program, tt.transaction, err, tt.wantErr)
+		}
+	}
+
+	// Blocked transactions are not audited, they never ran
+	if len(audited) != 5 || audited[0] != "allowed MMS200MI/GetItmBasic" {
+		t.Errorf("audited %q", audited)
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := map[string]float64{"12": 12, " 1.5 ": 1.5, "-3": -3, "": 0, "abc": 0}
+	for value, want := range tests {
+		if got := Number(value); got != want {
+			t.Errorf("Number(%q) = %g, want %g", value, got, want)
+		}
+	}
+	if got := FormatNumber(1.50); got != "1.5" {
+		t.Errorf("FormatNumber(1.50) = %q", got)
+	}
+	if got := FormatNumber(100); got != "100" {
+		t.Errorf("FormatNumber(100) = %q", got)
+	}
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
-	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -14,7 +13,9 @@ import (
 )
 
 var debugMode bool = false
-var checkM3 bool = false
+var erpProductName string = ""
+var checkHTTP2 bool = false
+var checkIdentityFlag bool = false
 
 // Define log file
 const logFile = "infor-test.log"
@@ -37,57 +38,24 @@ func checkDNSResolution(rawURL string) error {
 	if err != nil {
 		return fmt.Errorf("failed to parse URL: %v", err)
 	}
+	// Only the host is resolved, drop the port if the URL has one
+	if host, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = host
+	}
 	logger.Printf("🔍 Performing DNS resolution for %s", hostname)
-	_, err = net.LookupHost(hostname)
+	if ip, ok := resolveOverrideForHost(hostname); ok {
+		logger.Printf("🔀 DNS Resolution for %s overridden by --resolve: %s", hostname, ip)
+		if addrs, err := net.LookupHost(hostname); err == nil {
+			logger.Printf("   Public DNS currently resolves %s to %s", hostname, strings.Join(addrs, ", "))
+		}
+		return nil
+	}
+	addrs, err := net.LookupHost(hostname)
 	if err != nil {
 		logger.Printf("⚠️ DNS Resolution failed for %s: %v", hostname, err) // Log the error but don't stop execution
 		return nil                                                          // Return nil to continue program execution
 	}
-	logger.Printf("✅ DNS Resolution successful for %s", hostname)
-	return nil
-}
-func makeHTTPRequest(method, url, body string) error {
-	client := &http.Client{}
-
-	// Create a new HTTP request (e.g., POST, GET)
-	req, err := http.NewRequest(method, url, strings.NewReader(body))
-	if err != nil {
-		return fmt.Errorf("Failed to create HTTP request: %v", err)
-	}
-
-	// Add headers (e.g., Content-Type, Authorization) if needed
-	req.Header.Add("Content-Type", "application/json")
-
-	// Execute the request
-	resp, err := client.Do(req)
-	if err != nil {
-		logger.Printf("❌ Error making HTTP request to %s: %v", url, err)
-		return err
-	}
-	defer resp.Body.Close()
-
-	// Check for 405 Method Not Allowed
-	if resp.StatusCode == http.StatusMethodNotAllowed {
-		allowedMethods := resp.Header.Get("Allow")
-		logger.Printf("⚠️ 405 Method Not Allowed for URL: %s", url)
-		logger.Printf("   HTTP Method used: %s", method)
-		logger.Printf("   Allowed Methods: %s", allowedMethods)
-
-		// Log the response body for further debugging
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		logger.Printf("   Response Body: %s", string(bodyBytes))
-		return fmt.Errorf("405 Method Not Allowed: HTTP method %s is not allowed for URL %s", method, url)
-	}
-
-	// Log other status codes if needed
-	if resp.StatusCode != http.StatusOK {
-		logger.Printf("⚠️ Unexpected response: %d for URL %s", resp.StatusCode, url)
-		bodyBytes, _ := ioutil.ReadAll(resp.Body)
-		logger.Printf("   Response Body: %s", string(bodyBytes))
-	} else {
-		logger.Printf("✅ Request successful to %s", url)
-	}
-
+	logger.Printf("✅ DNS Resolution successful for %s (%s)", hostname, strings.Join(addrs, ", "))
 	return nil
 }
 
@@ -119,8 +87,13 @@ func checkNetworkConnectivity(rawURL, defaultPort string) error {
 	}
 
 	logger.Printf("🔍 Performing network connectivity check to %s on port %s", host, port)
-	address := fmt.Sprintf("%s:%s", host, port)
-	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	address := net.JoinHostPort(host, port)
+	if target := resolvedAddress(address); target != address {
+		logger.Printf("🔀 Connecting to %s instead (--resolve)", target)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := dialContext(ctx, "tcp", address)
 	if err != nil {
 		logger.Printf("⚠️ Network Connectivity check failed for %s on port %s: %v", host, port, err) // Log the error but don't stop execution
 		return nil                                                                                   // Return nil to continue program execution
@@ -151,8 +124,10 @@ func checkSSLCertificate(rawURL, port string) error {
 
 	logger.Printf("🔍 Checking SSL/TLS certificate for %s on port %s", host, extractedPort)
 
-	address := fmt.Sprintf("%s:%s", host, extractedPort)
-	conn, err := tls.Dial("tcp", address, &tls.Config{})
+	address := net.JoinHostPort(host, extractedPort)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := dialTLS(ctx, "tcp", address, &tls.Config{ServerName: host})
 	if err != nil {
 		logger.Printf("⚠️ SSL/TLS connection failed for %s: %v", host, err)
 		return nil
@@ -198,24 +173,109 @@ func removeProtocol(rawURL string) (string, error) {
 	return parsedURL.Host, nil
 }
 
+// usage describes the command line of the connection test and the available commands
+const usage = `Usage: Infor-test.exe <ionapi-file-path> [--debug] [--check_m3] [--product m3|csi|ln|auto] [--check_http2] [--check_identity] [--m3_checks <file>] [--report <file>] [--resolve <host:port:ip>]
+       Infor-test.exe <command> [arguments]
+
+  --product csi accepts --csi-config, --csi-ido, --csi-properties and --csi-record-cap; --product ln accepts --ln-service, --ln-entity and --ln-company.
+
+Commands:
+  allowlist <ionapi-file-path> [--hosts <file>] [--output <file>]
+  call <ionapi-file-path> <METHOD> <path> [--header 'Name: value' ...] [--query KEY=VALUE ...] [--data <json>|@<file>] [--file <file>]
+       [--content-type <type>] [--output <file>] [--include] [--policy <file>] [--allow-write <PROGRAM>/<TRANSACTION> ...]
+  call <ionapi-file-path> --spec <openapi.json|gateway path> [--op <operationId> [--param NAME=VALUE ...]] [--base-path <path>] [--data <json>|@<file>]
+  whoami <ionapi-file-path>
+  export <ionapi-file-path> [--output-dir <dir>] [--name <name>] [--placeholders]
+  suites <ionapi-file-path> [--catalog-path <path>] [--suites <file.json>] [--format table|json|csv]
+  datalake check <ionapi-file-path>
+  datalake query <ionapi-file-path> <SQL>|--sql-file <file> [--output <file.csv|file.jsonl>] [--page-size <n>] [--timeout <d>] [--poll <d>]
+  bod validate <file.xml> ... [--xsd <schema.xsd> ...] [--tenant <tenant-id>] [--logical-id <lid>]
+  idm check <ionapi-file-path>
+  idm search <ionapi-file-path> <xquery> [--limit <n>] [--offset <n>] [--format table|json|csv]
+  idm download <ionapi-file-path> <pid> [--output <file>]
+  idm upload <ionapi-file-path> [--entity <document type>] [--file <file>] [--size <bytes>] [--count <n>] [--keep]
+  ion send-bod <ionapi-file-path> <template.xml> --connection-point <logical-id> [--to <logical-id>] [--var NAME=VALUE ...]
+          [--document <Verb.Noun>] [--output <file>] [--track] [--track-timeout <d>] [--poll <d>] [--tracking-path <path>] [--skip-validation]
+  csi load <ionapi-file-path> <IDO> [--properties A,B] [--filter <filter>] [--record-cap <n>] [--config <configuration>] [--format table|json|csv]
+  csi invoke <ionapi-file-path> <IDO> <method> [--param <value> ...] [--config <configuration>]
+  m3 call <ionapi-file-path> <PROGRAM>/<TRANSACTION> [--param KEY=VALUE ...] [--cono <company>] [--divi <division>]
+          [--maxrecs <n>] [--returncols <A,B>] [--dateformat <fmt>] [--excludeempty] [--righttrim=false] [--format table|json|csv] [--metadata]
+  m3 bulk <ionapi-file-path> <input.csv|input.jsonl> [--batch-size <n>] [--output <file>] [--cono <company>] [--divi <division>]
+  m3 programs <ionapi-file-path> [--filter <text>] [--refresh] [--cache-dir <dir>] [--format table|json|csv]
+  m3 transactions <ionapi-file-path> <PROGRAM> [--refresh] [--cache-dir <dir>] [--format table|json|csv]
+  m3 fields <ionapi-file-path> <PROGRAM>/<TRANSACTION> [--refresh] [--cache-dir <dir>] [--format table|json|csv]
+  m3 codegen <ionapi-file-path> <PROGRAM> [--transactions A,B] [--package <name>] [--output <file>] [--refresh] [--cache-dir <dir>]
+  m3 export <ionapi-file-path> <PROGRAM>/<TRANSACTION> --key FIELD[,FIELD] [--param KEY=VALUE ...] [--page-size <n>] [--output <file>] [--resume]
+  m3 info <ionapi-file-path> [--format table|json|csv]
+  m3 bench <ionapi-file-path> <PROGRAM>/<TRANSACTION> [--param KEY=VALUE ...] [--concurrency <n>] [--duration <d>] [--rate <requests/s>]
+
+  All m3 subcommands accept --policy <file> and --allow-write <PROGRAM>/<TRANSACTION> for write transactions on production tenants.`
+
+// commands maps the name of a command to the function running it with the remaining arguments
+var commands = map[string]func(args []string) error{
+	"allowlist": runAllowlist,
+	"bod":       runBOD,
+	"call":      runCall,
+	"csi":       runCSI,
+	"datalake":  runDataLake,
+	"export":    runExport,
+	"idm":       runIDM,
+	"ion":       runIon,
+	"suites":    runSuites,
+	"whoami":    runWhoami,
+	"m3":        runM3,
+}
+
 func main() {
 	args := os.Args[1:]
 
 	if len(args) < 1 {
-		log.Fatal("Usage: Infor-test.exe <ionapi-file-path> [--debug] [--check_m3]")
+		log.Fatal(usage)
 	}
 
-	// Parse arguments for --debug and --check_m3 flags
-	ionAPIFile := args[0]
-	for _, arg := range args {
-		if arg == "--debug" {
-			debugMode = true
-			log.Println("Debug mode enabled")
+	if command, ok := commands[args[0]]; ok {
+		if err := command(args[1:]); err != nil {
+			fatalf("❌ %s failed: %v", args[0], err)
 		}
-		if arg == "--check_m3" {
-			checkM3 = true
-			log.Println("--check_m3 flag provided")
+		return
+	}
+
+	runConnectionTest(args)
+}
+
+// runConnectionTest runs the DNS, network, TLS and token checks against the .ionapi file
+func runConnectionTest(args []string) {
+	// Parse arguments for --debug, --check_m3, --product, --check_http2, --check_identity, --m3_checks, --report and --resolve flags
+	cli := parseArgs(args, append(erpProductFlags, "--m3_checks")...)
+	if len(cli.positional) < 1 {
+		log.Fatal(usage)
+	}
+	ionAPIFile := cli.positional[0]
+	applyGlobalFlags(cli)
+	if cli.has("--check_m3") {
+		erpProductName = "m3"
+		log.Println("--check_m3 flag provided")
+	}
+	if product := cli.value("--product", ""); product != "" {
+		erpProductName = product
+		log.Printf("--product %s flag provided", product)
+	}
+	if cli.has("--check_http2") {
+		checkHTTP2 = true
+		log.Println("--check_http2 flag provided")
+	}
+	if cli.has("--check_identity") {
+		checkIdentityFlag = true
+		log.Println("--check_identity flag provided")
+	}
+	var m3Suite *m3CheckSuite
+	if file := cli.value("--m3_checks", ""); file != "" {
+		suite, err := loadM3CheckSuite(file)
+		if err != nil {
+			fatalf("%v", err)
 		}
+		m3Suite = suite
+		log.Printf("--m3_checks flag provided, %d check(s) loaded from %s", len(m3Suite.Checks), file)
 	}
 
 	log.Printf("Loading ionapi file: %s\n", ionAPIFile)
@@ -223,9 +283,12 @@ func main() {
 	// Load the ionAPI file
 	ionAPI, err := loadIonAPI(ionAPIFile)
 	if err != nil {
-		log.Fatalf("Failed to load ionapi file: %v", err)
+		fatalf("Failed to load ionapi file: %v", err)
 	}
 	log.Println("Successfully loaded ionapi file")
+	report.IonBaseURL = ionAPI.IonBaseURL
+	report.TenantID = ionAPI.TenantID
+	report.Resolve = resolveOverrides
 
 	// Print loaded data if debug mode is enabled
 	debugPrint("ION API Gateway URL: %s", ionAPI.IonBaseURL)
@@ -237,7 +300,7 @@ func main() {
 
 	// Perform DNS resolution check for ION Base URL
 	if err := checkDNSResolution(ionAPI.IonBaseURL); err != nil {
-		log.Fatalf("❌ DNS Resolution failed for %s: %v", ionAPI.IonBaseURL, err)
+		fatalf("❌ DNS Resolution failed for %s: %v", ionAPI.IonBaseURL, err)
 	} else {
 		log.Printf("✅ DNS Resolution successful for %s", ionAPI.IonBaseURL)
 	}
@@ -250,7 +313,7 @@ func main() {
 
 	// Perform Network Connectivity check for ION Base URL
 	if err := checkNetworkConnectivity(ionAPI.IonBaseURL, port); err != nil {
-		logger.Fatalf("❌ Network Connectivity check failed: %v", err)
+		fatalf("❌ Network Connectivity check failed: %v", err)
 	} else {
 		logger.Printf("✅ Network Connectivity successful to %s on port %s", ionAPI.IonBaseURL, port)
 	}
@@ -264,12 +327,26 @@ func main() {
 
 	// Proceed with the rest of the program (e.g., obtaining access tokens, etc.)
 
+	// Probe the ION API Gateway, a 401 without a token still proves it is reachable
+	gatewayURL := fmt.Sprintf("%s/%s", ionAPI.IonBaseURL, ionAPI.TenantID)
+	if checkConnectivity(gatewayURL, "ION API Gateway") {
+		log.Printf("✅ Successfully connected to ION API Gateway (%s)\n", gatewayURL)
+	} else {
+		fatalf("❌ Failed to connect to ION API Gateway (%s)\n", gatewayURL)
+	}
+
 	// Check connectivity to Authorization Server (use the full token URL)
 	tokenURL := ionAPI.GetTokenURL() // Use the full URL for checking connectivity
 	if checkConnectivity(tokenURL, "Authorization Server") {
 		log.Printf("✅ Successfully connected to Authorization Server (%s)\n", tokenURL)
 	} else {
-		log.Fatalf("❌ Failed to connect to Authorization Server (%s)\n", tokenURL)
+		fatalf("❌ Failed to connect to Authorization Server (%s)\n", tokenURL)
+	}
+
+	// Compare HTTP/1.1 and HTTP/2 against the gateway and the STS if requested
+	if checkHTTP2 {
+		checkProtocolNegotiation(gatewayURL, "ION API Gateway")
+		checkProtocolNegotiation(tokenURL, "Authorization Server")
 	}
 
 	// These connection messages should only be printed once
@@ -278,24 +355,43 @@ func main() {
 	// Obtain the access token
 	token, err := getAccessToken(ionAPI)
 	if err != nil {
-		log.Fatalf("Failed to get access token: %v", err)
+		fatalf("Failed to get access token: %v", err)
 	}
 	log.Println("✅ Connection successful! Access token obtained successfully.")
 
 	// Print the access token if debug mode is enabled
 	debugPrint("Access Token: %s", token)
 
-	// If the --check_m3 flag is present, make the M3 API call
-	if checkM3 {
-		log.Println("Calling M3 API...")
-		err := checkM3API(token, ionAPI.IonBaseURL, ionAPI.TenantID)
-		if err != nil {
-			log.Fatalf("Failed to check M3 API: %v", err)
+	// Tell a token without permissions apart from connectivity problems
+	if checkIdentityFlag {
+		identity := checkIdentity(newIonClient(ionAPI, token))
+		printIdentity(identity)
+	}
+
+	// If --check_m3 or --product is present, call the API of the ERP product
+	if erpProductName != "" {
+		if err := runERPCheck(newIonClient(ionAPI, token), erpProductName, cli); err != nil {
+			fatalf("Failed to check %s API: %v", report.ERP.Product, err)
 		}
-		log.Println("Successfully called M3 API")
 	} else {
-		log.Println("No M3 check requested, skipping")
+		log.Println("No ERP product check requested, skipping")
 	}
 
+	// Run the M3 health checks of --m3_checks
+	if m3Suite != nil {
+		if failed := runM3Checks(newM3Client(token, ionAPI.IonBaseURL, ionAPI.TenantID), m3Suite); failed > 0 {
+			fatalf("❌ %d of %d M3 check(s) failed", failed, len(m3Suite.Checks))
+		}
+		log.Printf("✅ All %d M3 check(s) passed", len(m3Suite.Checks))
+	}
+
+	finishReport()
 	log.Println("Program finished successfully")
 }
+
+// fatalf prints and saves the report, so a failed run keeps the results gathered so far, and exits
+// with the message
+func fatalf(format string, v ...interface{}) {
+	finishReport()
+	log.Fatalf(format, v...)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// testGateway is a fake ION API Gateway for tenant ACME_TST
+type testGateway struct {
+	*httptest.Server
+	// ionAPIFile is an .ionapi file for the gateway
+	ionAPIFile string
+	// tokens counts the token requests
+	tokens int32
+}
+
+// newTestGateway starts a fake ION API Gateway. Its token endpoint hands out test-token and all
+// other requests go to the handler.
+func newTestGateway(t *testing.T, handler http.Handler) *testGateway {
+	t.Helper()
+	gateway := &testGateway{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/as/token.oauth2", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gateway.tokens, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "test-token", "token_type": "Bearer", "expires_in": 7200}`))
+	})
+	mux.Handle("/", handler)
+	gateway.Server = httptest.NewServer(mux)
+	t.Cleanup(gateway.Close)
+
+	data, err := json.Marshal(IonAPI{
+		ClientID:     "ACME_TST~client",
+		ClientSecret: "secret",
+		TokenBaseURL: gateway.URL + "/",
+		TokenPath:    "as/token.oauth2",
+		Username:     "ACME_TST#saak",
+		Password:     "sask",
+		IonBaseURL:   gateway.URL,
+		TenantID:     "ACME_TST",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway.ionAPIFile = filepath.Join(t.TempDir(), "test.ionapi")
+	if err := ioutil.WriteFile(gateway.ionAPIFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return gateway
+}
+
+// writeM3Records answers an execute request with the records as the result of a single transaction
+func writeM3Records(w http.ResponseWriter, transaction string, records []map[string]string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m3Response{Results: []m3Result{{Transaction: transaction, Records: records}}})
+}
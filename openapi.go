@@ -0,0 +1,579 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// openAPIMethods are the operations of a path item, in the order they are listed
+var openAPIMethods = []string{"get", "put", "post", "delete", "patch", "head", "options"}
+
+// maxSchemaErrors limits the schema errors reported for a single response
+const maxSchemaErrors = 20
+
+// openAPISpec is the part of an OpenAPI 3 or Swagger 2 document needed to build and check requests
+type openAPISpec struct {
+	Swagger     string                                `json:"swagger"`
+	OpenAPI     string                                `json:"openapi"`
+	BasePath    string                                `json:"basePath"`
+	Servers     []struct{ URL string }                `json:"servers"`
+	Paths       map[string]map[string]json.RawMessage `json:"paths"`
+	Definitions map[string]*jsonSchema                `json:"definitions"`
+	Parameters  map[string]*openAPIParameter          `json:"parameters"`
+	Components  struct {
+		Schemas    map[string]*jsonSchema       `json:"schemas"`
+		Parameters map[string]*openAPIParameter `json:"parameters"`
+	} `json:"components"`
+}
+
+// openAPIOperation is an operation of the document with the method and path it is found at
+type openAPIOperation struct {
+	OperationID string              `json:"operationId"`
+	Summary     string              `json:"summary"`
+	Parameters  []*openAPIParameter `json:"parameters"`
+	Consumes    []string            `json:"consumes"`
+	RequestBody *struct {
+		Required bool                        `json:"required"`
+		Content  map[string]openAPIMediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Schema  *jsonSchema                 `json:"schema"`
+		Content map[string]openAPIMediaType `json:"content"`
+	} `json:"responses"`
+	method string
+	path   string
+}
+
+// openAPIMediaType is the schema of a request or response body for a content type
+type openAPIMediaType struct {
+	Schema *jsonSchema `json:"schema"`
+}
+
+// openAPIParameter is a path, query, header or cookie parameter, or a (Swagger 2) body or formData parameter
+type openAPIParameter struct {
+	Ref      string        `json:"$ref"`
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required"`
+	Type     string        `json:"type"`
+	Enum     []interface{} `json:"enum"`
+	Schema   *jsonSchema   `json:"schema"`
+}
+
+// jsonSchema is the subset of JSON Schema used to validate parameters and bodies
+type jsonSchema struct {
+	Ref        string                 `json:"$ref"`
+	Type       string                 `json:"type"`
+	Enum       []interface{}          `json:"enum"`
+	Required   []string               `json:"required"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Items      *jsonSchema            `json:"items"`
+	AllOf      []*jsonSchema          `json:"allOf"`
+}
+
+// loadOpenAPISpec reads an OpenAPI document from a file or, when no such file exists, from a
+// gateway path or URL. Only JSON documents are supported.
+func loadOpenAPISpec(client *ionClient, source string) (*openAPISpec, error) {
+	var content []byte
+	if _, err := os.Stat(source); err == nil {
+		if content, err = ioutil.ReadFile(source); err != nil {
+			return nil, err
+		}
+	} else if client != nil {
+		req, err := client.newRequest("GET", source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, body, err := client.do(req, "OpenAPI document")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, &httpStatusError{Method: req.Method, URL: req.URL.String(), StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+		}
+		content = body
+	} else {
+		return nil, err
+	}
+
+	var spec openAPISpec
+	if err := json.Unmarshal(content, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse the OpenAPI document %s, only JSON is supported: %v", source, err)
+	}
+	if spec.Swagger == "" && spec.OpenAPI == "" {
+		return nil, fmt.Errorf("%s is not an OpenAPI or Swagger document", source)
+	}
+	return &spec, nil
+}
+
+// operations returns the operations of the document sorted by path and method
+func (s *openAPISpec) operations() ([]*openAPIOperation, error) {
+	paths := make([]string, 0, len(s.Paths))
+	for path := range s.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var operations []*openAPIOperation
+	for _, path := range paths {
+		item := s.Paths[path]
+		var shared []*openAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("invalid parameters of %s: %v", path, err)
+			}
+		}
+		for _, method := range openAPIMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			operation := &openAPIOperation{method: strings.ToUpper(method), path: path}
+			if err := json.Unmarshal(raw, operation); err != nil {
+				return nil, fmt.Errorf("invalid operation %s %s: %v", method, path, err)
+			}
+			parameters := make([]*openAPIParameter, 0, len(shared)+len(operation.Parameters))
+			for _, parameter := range append(append([]*openAPIParameter{}, shared...), operation.Parameters...) {
+				parameters = append(parameters, s.parameter(parameter))
+			}
+			operation.Parameters = parameters
+			operations = append(operations, operation)
+		}
+	}
+	return operations, nil
+}
+
+// operation returns the operation with the operationId, compared case-insensitively
+func (s *openAPISpec) operation(id string) (*openAPIOperation, error) {
+	operations, err := s.operations()
+	if err != nil {
+		return nil, err
+	}
+	for _, operation := range operations {
+		if strings.EqualFold(operation.OperationID, id) {
+			return operation, nil
+		}
+	}
+	return nil, fmt.Errorf("operation %q not found in the OpenAPI document, list the operations with --spec without --op", id)
+}
+
+// parameter resolves a $ref to a shared parameter
+func (s *openAPISpec) parameter(p *openAPIParameter) *openAPIParameter {
+	if p.Ref == "" {
+		return p
+	}
+	name := p.Ref[strings.LastIndex(p.Ref, "/")+1:]
+	if resolved, ok := s.Components.Parameters[name]; ok {
+		return resolved
+	}
+	if resolved, ok := s.Parameters[name]; ok {
+		return resolved
+	}
+	return p
+}
+
+// schema resolves a $ref to a schema of the definitions or components
+func (s *openAPISpec) schema(schema *jsonSchema) *jsonSchema {
+	for depth := 0; schema != nil && schema.Ref != "" && depth < 10; depth++ {
+		name := schema.Ref[strings.LastIndex(schema.Ref, "/")+1:]
+		if resolved, ok := s.Components.Schemas[name]; ok {
+			schema = resolved
+		} else if resolved, ok := s.Definitions[name]; ok {
+			schema = resolved
+		} else {
+			return nil
+		}
+	}
+	return schema
+}
+
+// basePath returns the path the operation paths are relative to, the path of the first server for OpenAPI 3
+func (s *openAPISpec) basePath() string {
+	if s.BasePath != "" {
+		return s.BasePath
+	}
+	if len(s.Servers) > 0 {
+		if server, err := url.Parse(s.Servers[0].URL); err == nil {
+			return server.Path
+		}
+	}
+	return ""
+}
+
+// bodySchema returns the schema of the request body, from requestBody or a Swagger 2 body parameter
+func (o *openAPIOperation) bodySchema() (*jsonSchema, bool) {
+	if o.RequestBody != nil {
+		for contentType, media := range o.RequestBody.Content {
+			if strings.Contains(contentType, "json") {
+				return media.Schema, o.RequestBody.Required
+			}
+		}
+		return nil, o.RequestBody.Required
+	}
+	for _, parameter := range o.Parameters {
+		if parameter.In == "body" {
+			return parameter.Schema, parameter.Required
+		}
+	}
+	return nil, false
+}
+
+// responseSchema returns the JSON schema of the response for a status code, falling back to
+// the 2XX range and the default response
+func (o *openAPIOperation) responseSchema(statusCode int) *jsonSchema {
+	code := strconv.Itoa(statusCode)
+	for _, key := range []string{code, code[:1] + "XX", "default"} {
+		response, ok := o.Responses[key]
+		if !ok {
+			continue
+		}
+		if response.Schema != nil {
+			return response.Schema
+		}
+		for contentType, media := range response.Content {
+			if strings.Contains(contentType, "json") {
+				return media.Schema
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// newOperationRequest builds the request of an operation from NAME=VALUE parameters and a JSON body,
+// checking that required parameters are present, unknown ones are not and values match their type
+func newOperationRequest(client *ionClient, spec *openAPISpec, operation *openAPIOperation, params []string, body []byte, basePath string) (*http.Request, error) {
+	values := map[string]string{}
+	for _, param := range params {
+		name, value, ok := strings.Cut(param, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected NAME=VALUE", param)
+		}
+		values[name] = value
+	}
+
+	path := operation.path
+	query := url.Values{}
+	headers := map[string]string{}
+	var cookies []*http.Cookie
+	form := url.Values{}
+	multipartForm := false
+	for _, consumes := range operation.Consumes {
+		multipartForm = multipartForm || strings.HasPrefix(consumes, "multipart/form-data")
+	}
+	known := map[string]bool{}
+	var names []string
+	for _, parameter := range operation.Parameters {
+		if parameter.In == "body" {
+			continue
+		}
+		known[parameter.Name] = true
+		names = append(names, parameter.Name)
+		value, ok := values[parameter.Name]
+		if !ok {
+			if parameter.Required {
+				return nil, fmt.Errorf("missing required %s parameter %s of %s", parameter.In, parameter.Name, operation.OperationID)
+			}
+			continue
+		}
+		if err := checkParameterValue(spec, parameter, value); err != nil {
+			return nil, err
+		}
+		switch parameter.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+parameter.Name+"}", url.PathEscape(value))
+		case "query":
+			query.Add(parameter.Name, value)
+		case "header":
+			headers[parameter.Name] = value
+		case "cookie":
+			cookies = append(cookies, &http.Cookie{Name: parameter.Name, Value: value})
+		case "formData":
+			form.Add(parameter.Name, value)
+			multipartForm = multipartForm || parameter.Type == "file"
+		default:
+			return nil, fmt.Errorf("unsupported parameter location %q of parameter %s", parameter.In, parameter.Name)
+		}
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown parameter %s of %s, expected one of: %s", name, operation.OperationID, strings.Join(names, ", "))
+		}
+	}
+
+	schema, required := operation.bodySchema()
+	if required && len(body) == 0 {
+		return nil, fmt.Errorf("%s requires a request body, set it with --data", operation.OperationID)
+	}
+	if len(body) > 0 && schema != nil {
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return nil, fmt.Errorf("request body is not valid JSON: %v", err)
+		}
+		if errs := spec.validate(schema, value, "$"); len(errs) > 0 {
+			return nil, fmt.Errorf("request body does not match the schema of %s: %s", operation.OperationID, strings.Join(errs, "; "))
+		}
+	}
+
+	contentType := ""
+	if len(form) > 0 {
+		if len(body) > 0 {
+			return nil, fmt.Errorf("%s takes formData parameters, they cannot be combined with --data", operation.OperationID)
+		}
+		var err error
+		if body, contentType, err = formBody(form, multipartForm); err != nil {
+			return nil, err
+		}
+	}
+
+	fullPath := strings.TrimSuffix(basePath, "/") + path
+	if len(query) > 0 {
+		fullPath += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if len(body) > 0 {
+		reader = bytes.NewReader(body)
+	}
+	req, err := client.newRequest(operation.method, fullPath, reader)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return req, nil
+}
+
+// formBody encodes formData parameters as an urlencoded body, or as a multipart body when the
+// operation consumes multipart/form-data. In a multipart body a value @<file> sends the file.
+func formBody(form url.Values, multipartForm bool) ([]byte, string, error) {
+	if !multipartForm {
+		return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	names := make([]string, 0, len(form))
+	for name := range form {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range form[name] {
+			if !strings.HasPrefix(value, "@") {
+				if err := writer.WriteField(name, value); err != nil {
+					return nil, "", err
+				}
+				continue
+			}
+			content, err := ioutil.ReadFile(value[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			part, err := writer.CreateFormFile(name, filepath.Base(value[1:]))
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(content); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+// checkParameterValue checks that a parameter value matches the type and enum of the parameter
+func checkParameterValue(spec *openAPISpec, parameter *openAPIParameter, value string) error {
+	schema := &jsonSchema{Type: parameter.Type, Enum: parameter.Enum}
+	if parameter.Schema != nil {
+		schema = spec.schema(parameter.Schema)
+	}
+	if schema == nil {
+		return nil
+	}
+
+	var typed interface{} = value
+	switch schema.Type {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("parameter %s must be an integer, got %q", parameter.Name, value)
+		}
+		typed = float64(n)
+	case "number":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("parameter %s must be a number, got %q", parameter.Name, value)
+		}
+		typed = n
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("parameter %s must be true or false, got %q", parameter.Name, value)
+		}
+		typed = b
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, typed) {
+		return fmt.Errorf("parameter %s must be one of %v, got %q", parameter.Name, schema.Enum, value)
+	}
+	return nil
+}
+
+// validate checks a decoded JSON value against a schema and returns the mismatches with their
+// JSON path. Null values are accepted, formats and patterns are not checked.
+func (s *openAPISpec) validate(schema *jsonSchema, value interface{}, path string) []string {
+	schema = s.schema(schema)
+	if schema == nil || value == nil {
+		return nil
+	}
+
+	var errs []string
+	for _, part := range schema.AllOf {
+		errs = append(errs, s.validate(part, value, path)...)
+	}
+	kind := schema.Type
+	if kind == "" && schema.Properties != nil {
+		kind = "object"
+	}
+
+	switch kind {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected object, got %s", path, jsonType(value)))
+		}
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: missing required property %s", path, name))
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := object[name]; ok {
+				errs = append(errs, s.validate(schema.Properties[name], property, path+"."+name)...)
+			}
+		}
+	case "array":
+		list, ok := value.([]interface{})
+		if !ok {
+			return append(errs, fmt.Sprintf("%s: expected array, got %s", path, jsonType(value)))
+		}
+		for i, item := range list {
+			errs = append(errs, s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			if len(errs) >= maxSchemaErrors {
+				break
+			}
+		}
+	case "string", "boolean", "number", "integer":
+		actual := jsonType(value)
+		if actual != kind && !(kind == "number" && actual == "integer") {
+			errs = append(errs, fmt.Sprintf("%s: expected %s, got %s", path, kind, actual))
+		}
+	}
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, value, schema.Enum))
+	}
+	if len(errs) > maxSchemaErrors {
+		errs = errs[:maxSchemaErrors]
+	}
+	return errs
+}
+
+// jsonType returns the JSON Schema type of a decoded JSON value
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "null"
+}
+
+// inEnum reports whether a value is one of the enum values
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOperationResponse validates a JSON response against the schema of the operation for its
+// status and logs the mismatches
+func checkOperationResponse(spec *openAPISpec, operation *openAPIOperation, resp *http.Response, body []byte) []string {
+	schema := operation.responseSchema(resp.StatusCode)
+	if schema == nil || !(strings.Contains(resp.Header.Get("Content-Type"), "json") || json.Valid(body)) {
+		debugPrint("No JSON schema for response %d of %s", resp.StatusCode, operation.OperationID)
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+	}
+	errs := spec.validate(schema, value, "$")
+	for _, err := range errs {
+		logger.Printf("⚠️ Schema mismatch: %s", err)
+	}
+	if len(errs) == 0 {
+		logger.Printf("✅ Response matches the schema of %s", operation.OperationID)
+	}
+	return errs
+}
+
+// printOperations lists the operations of the document with their parameters
+func printOperations(spec *openAPISpec) error {
+	operations, err := spec.operations()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "OPERATION\tMETHOD\tPATH\tPARAMETERS\tSUMMARY")
+	for _, operation := range operations {
+		var params []string
+		for _, parameter := range operation.Parameters {
+			name := parameter.Name
+			if parameter.Required {
+				name += "*"
+			}
+			params = append(params, name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", operation.OperationID, operation.method, operation.path, strings.Join(params, ","), operation.Summary)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// testSpec is a Swagger 2 document with every parameter location the tool supports
+const testSpec = `{
+  "swagger": "2.0",
+  "basePath": "/IFS/usermgt/v2",
+  "parameters": {
+    "Expand": {"name": "expand", "in": "query", "type": "string", "enum": ["groups", "roles"]}
+  },
+  "definitions": {
+    "User": {
+      "type": "object",
+      "required": ["userName"],
+      "properties": {
+        "userName": {"type": "string"},
+        "age": {"type": "integer"},
+        "status": {"type": "string", "enum": ["active", "inactive"]},
+        "groups": {"type": "array", "items": {"$ref": "#/definitions/Group"}}
+      }
+    },
+    "Group": {"type": "object", "properties": {"name": {"type": "string"}}}
+  },
+  "paths": {
+    "/users/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true, "type": "string"}],
+      "get": {
+        "operationId": "getUser",
+        "parameters": [
+          {"$ref": "#/parameters/Expand"},
+          {"name": "limit", "in": "query", "type": "integer"},
+          {"name": "X-Trace", "in": "header", "type": "string"},
+          {"name": "session", "in": "cookie", "type": "string"}
+        ]
+      }
+    },
+    "/users": {
+      "post": {
+        "operationId": "createUser",
+        "parameters": [{"name": "user", "in": "body", "required": true, "schema": {"$ref": "#/definitions/User"}}]
+      }
+    },
+    "/login": {
+      "post": {
+        "operationId": "login",
+        "parameters": [
+          {"name": "user", "in": "formData", "type": "string", "required": true},
+          {"name": "remember", "in": "formData", "type": "boolean"}
+        ]
+      }
+    },
+    "/avatar": {
+      "post": {
+        "operationId": "uploadAvatar",
+        "consumes": ["multipart/form-data"],
+        "parameters": [{"name": "title", "in": "formData", "type": "string"}]
+      }
+    },
+    "/matrix": {
+      "get": {
+        "operationId": "matrix",
+        "parameters": [{"name": "m", "in": "matrix", "type": "string"}]
+      }
+    }
+  }
+}`
+
+// loadTestSpec parses testSpec
+func loadTestSpec(t *testing.T) *openAPISpec {
+	t.Helper()
+	var spec openAPISpec
+	if err := json.Unmarshal([]byte(testSpec), &spec); err != nil {
+		t.Fatalf("failed to parse the test document: %v", err)
+	}
+	return &spec
+}
+
+func TestNewOperationRequest(t *testing.T) {
+	spec := loadTestSpec(t)
+	client := newIonClient(&IonAPI{IonBaseURL: "https://gateway.example.com", TenantID: "ACME_TST"}, "token")
+	tests := []struct {
+		name        string
+		operation   string
+		params      []string
+		body        string
+		method      string
+		url         string
+		headers     map[string]string
+		contentType string
+		form        string
+		wantErr     string
+	}{
+		{
+			name:      "path, query, header and cookie",
+			operation: "getUser",
+			params:    []string{"id=me/x", "expand=groups", "limit=5", "X-Trace=abc", "session=s1"},
+			method:    "GET",
+			url:       "https://gateway.example.com/ACME_TST/IFS/usermgt/v2/users/me%2Fx?expand=groups&limit=5",
+			headers:   map[string]string{"X-Trace": "abc", "Cookie": "session=s1"},
+		},
+		{name: "missing path parameter", operation: "getUser", wantErr: "missing required path parameter id"},
+		{name: "unknown parameter", operation: "getUser", params: []string{"id=me", "foo=bar"}, wantErr: "unknown parameter foo"},
+		{name: "integer", operation: "getUser", params: []string{"id=me", "limit=many"}, wantErr: "must be an integer"},
+		{name: "enum", operation: "getUser", params: []string{"id=me", "expand=all"}, wantErr: "expand"},
+		{name: "invalid parameter", operation: "getUser", params: []string{"id"}, wantErr: "expected NAME=VALUE"},
+		{
+			name:      "body",
+			operation: "createUser",
+			body:      `{"userName": "jdoe", "age": 42}`,
+			method:    "POST",
+			url:       "https://gateway.example.com/ACME_TST/IFS/usermgt/v2/users",
+		},
+		{name: "missing body", operation: "createUser", wantErr: "requires a request body"},
+		{name: "body not matching the schema", operation: "createUser", body: `{"age": "old"}`, wantErr: "does not match the schema"},
+		{name: "body not JSON", operation: "createUser", body: `user=jdoe`, wantErr: "not valid JSON"},
+		{
+			name:        "urlencoded form",
+			operation:   "login",
+			params:      []string{"user=jdoe", "remember=true"},
+			method:      "POST",
+			url:         "https://gateway.example.com/ACME_TST/IFS/usermgt/v2/login",
+			contentType: "application/x-www-form-urlencoded",
+			form:        "remember=true&user=jdoe",
+		},
+		{name: "form with body", operation: "login", params: []string{"user=jdoe"}, body: `{}`, wantErr: "cannot be combined with --data"},
+		{
+			name:        "multipart form",
+			operation:   "uploadAvatar",
+			params:      []string{"title=Me"},
+			method:      "POST",
+			url:         "https://gateway.example.com/ACME_TST/IFS/usermgt/v2/avatar",
+			contentType: "multipart/form-data",
+			form:        `name="title"`,
+		},
+		{name: "unsupported location", operation: "matrix", params: []string{"m=1"}, wantErr: "unsupported parameter location"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation, err := spec.operation(tt.operation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := newOperationRequest(client, spec, operation, tt.params, []byte(tt.body), spec.basePath())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("newOperationRequest() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newOperationRequest() failed: %v", err)
+			}
+			if req.Method != tt.method || req.URL.String() != tt.url {
+				t.Errorf("request = %s %s, want %s %s", req.Method, req.URL, tt.method, tt.url)
+			}
+			if got := req.Header.Get("Authorization"); got != "Bearer token" {
+				t.Errorf("Authorization = %q, want the bearer token", got)
+			}
+			for name, want := range tt.headers {
+				if got := req.Header.Get(name); got != want {
+					t.Errorf("header %s = %q, want %q", name, got, want)
+				}
+			}
+			if got := req.Header.Get("Content-Type"); !strings.HasPrefix(got, tt.contentType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if tt.form != "" {
+				body, _ := ioutil.ReadAll(req.Body)
+				if !strings.Contains(string(body), tt.form) {
+					t.Errorf("body = %q, want it to contain %q", body, tt.form)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAPISpecValidate(t *testing.T) {
+	spec := loadTestSpec(t)
+	user := &jsonSchema{Ref: "#/definitions/User"}
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "valid", value: `{"userName": "jdoe", "age": 42, "status": "active", "groups": [{"name": "Sales"}]}`},
+		{name: "null values are accepted", value: `{"userName": "jdoe", "age": null}`},
+		{name: "missing required", value: `{"age": 42}`, want: []string{"$: missing required property userName"}},
+		{name: "wrong type", value: `{"userName": 1, "age": 4.5}`, want: []string{"$.age: expected integer, got number", "$.userName: expected string, got integer"}},
+		{name: "enum", value: `{"userName": "jdoe", "status": "gone"}`, want: []string{"$.status: gone is not one of [active inactive]"}},
+		{name: "array items", value: `{"userName": "jdoe", "groups": [{"name": 1}, "x"]}`, want: []string{"$.groups[0].name: expected string, got integer", "$.groups[1]: expected object, got string"}},
+		{name: "not an object", value: `[]`, want: []string{"$: expected object, got array"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.value), &value); err != nil {
+				t.Fatal(err)
+			}
+			got := spec.validate(user, value, "$")
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
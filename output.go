@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// column describes a field of the records being printed. The JSON names match the field
+// metadata returned by M3.
+type column struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Length      int    `json:"length,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// numeric reports whether the column holds numbers, M3 uses type N for numeric fields
+func (c column) numeric() bool {
+	return c.Type == "N"
+}
+
+// columnNames returns the names of the columns
+func columnNames(columns []column) []string {
+	names := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+// reserveStdout sends the log output to stderr when the data is printed as JSON or CSV,
+// so stdout can be piped to a file or another program
+func reserveStdout(format string) {
+	if f := strings.ToLower(format); f == "json" || f == "csv" {
+		logger.SetOutput(os.Stderr)
+	}
+}
+
+// printRecords writes records as a table, JSON or CSV, with the fields in the order of the columns.
+// In JSON, numeric columns are written as numbers.
+func printRecords(w io.Writer, format string, columns []column, records []map[string]string) error {
+	fields := columnNames(columns)
+	switch strings.ToLower(format) {
+	case "", "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(fields, "\t"))
+		for _, record := range records {
+			values := make([]string, len(fields))
+			for i, field := range fields {
+				values[i] = record[field]
+			}
+			fmt.Fprintln(tw, strings.Join(values, "\t"))
+		}
+		return tw.Flush()
+	case "json":
+		data, err := json.MarshalIndent(typedRecords(columns, records), "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(fields)
+		for _, record := range records {
+			values := make([]string, len(fields))
+			for i, field := range fields {
+				values[i] = record[field]
+			}
+			cw.Write(values)
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown output format %q, expected table, json or csv", format)
+	}
+}
+
+// typedRecords converts the values of numeric columns to JSON numbers, keeping the text when
+// a value does not parse
+func typedRecords(columns []column, records []map[string]string) []map[string]interface{} {
+	typed := make([]map[string]interface{}, len(records))
+	for i, record := range records {
+		typed[i] = make(map[string]interface{}, len(record))
+		for name, value := range record {
+			typed[i][name] = value
+		}
+		for _, c := range columns {
+			value, ok := record[c.Name]
+			if !ok || !c.numeric() {
+				continue
+			}
+			if _, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				typed[i][c.Name] = json.Number(strings.TrimSpace(value))
+			}
+		}
+	}
+	return typed
+}
+
+// printColumns writes the field metadata of the columns as a table
+func printColumns(w io.Writer, columns []column) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tTYPE\tLENGTH\tDESCRIPTION")
+	for _, c := range columns {
+		length := ""
+		if c.Length > 0 {
+			length = strconv.Itoa(c.Length)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Name, c.Type, length, c.Description)
+	}
+	return tw.Flush()
+}
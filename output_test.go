@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"inforconnectiontest/m3api"
+)
+
+func TestM3Columns(t *testing.T) {
+	result := &m3Result{
+		Records: []map[string]string{{"ITNO": "A1", "STAT": "20", "ZZEX": "x"}, {"ITNO": "A2", "AAEX": "y"}},
+	}
+	result.Metadata.Fields = []m3api.Field{{Name: "ITNO", Type: "A", Length: 15}, {Name: "STAT", Type: "N"}}
+
+	want := []column{{Name: "ITNO", Type: "A", Length: 15}, {Name: "STAT", Type: "N"}, {Name: "AAEX"}, {Name: "ZZEX"}}
+	if got := m3Columns(result); !reflect.DeepEqual(got, want) {
+		t.Errorf("m3Columns() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPrintRecords(t *testing.T) {
+	columns := []column{{Name: "ITNO"}, {Name: "NEWE", Type: "N"}, {Name: "UNMS", Type: "N"}}
+	records := []map[string]string{{"ITNO": "A1", "NEWE": "1.5", "UNMS": "PCS"}, {"ITNO": "A 2", "NEWE": " 10 "}}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{format: "table", want: "ITNO  NEWE  UNMS\nA1    1.5   PCS\nA 2    10   \n"},
+		{format: "CSV", want: "ITNO,NEWE,UNMS\nA1,1.5,PCS\nA 2,\" 10 \",\n"},
+		{format: "json", want: `[
+  {
+    "ITNO": "A1",
+    "NEWE": 1.5,
+    "UNMS": "PCS"
+  },
+  {
+    "ITNO": "A 2",
+    "NEWE": 10
+  }
+]
+`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if err := printRecords(&out, tt.format, columns, records); err != nil {
+			t.Fatalf("printRecords(%s) failed: %v", tt.format, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("printRecords(%s) =\n%q\nwant\n%q", tt.format, out.String(), tt.want)
+		}
+	}
+
+	if err := printRecords(&bytes.Buffer{}, "xml", columns, records); err == nil {
+		t.Error("printRecords() accepted an unknown format")
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// protocolProxy returns the proxy of a request of the protocol check
+var protocolProxy = http.ProxyFromEnvironment
+
+// protocolSamples is the number of warm requests sent per protocol to compare latency
+const protocolSamples = 3
+
+// protocolAttempt is the outcome of calling an endpoint with one forced HTTP protocol
+type protocolAttempt struct {
+	Protocol   string        `json:"protocol"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Proto      string        `json:"response_proto,omitempty"`
+	Cold       time.Duration `json:"-"`
+	Warm       time.Duration `json:"-"`
+	ColdMS     float64       `json:"cold_ms,omitempty"`
+	WarmMS     float64       `json:"warm_avg_ms,omitempty"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// protocolResult compares HTTP/1.1 and HTTP/2 against a single endpoint
+type protocolResult struct {
+	ServiceName string          `json:"service"`
+	URL         string          `json:"url"`
+	Proxy       string          `json:"proxy,omitempty"`
+	ALPN        string          `json:"alpn,omitempty"`
+	ALPNError   string          `json:"alpn_error,omitempty"`
+	HTTP1       protocolAttempt `json:"http1"`
+	HTTP2       protocolAttempt `json:"http2"`
+}
+
+// checkProtocolNegotiation reports the ALPN result for the endpoint and compares the behaviour
+// and latency of requests forced to HTTP/1.1 and HTTP/2
+func checkProtocolNegotiation(rawURL, serviceName string) {
+	result := protocolResult{ServiceName: serviceName, URL: rawURL}
+	defer func() { report.addProtocol(result) }()
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		logger.Printf("⚠️ Protocol check failed: could not parse URL %s: %v", rawURL, err)
+		return
+	}
+	if parsedURL.Scheme != "https" {
+		logger.Printf("⚠️ Skipping protocol check for %s: ALPN and HTTP/2 require https", serviceName)
+		return
+	}
+
+	logger.Printf("🔍 Checking protocol negotiation for %s (%s)", serviceName, rawURL)
+	// Both the ALPN check and the measurements go through the proxy the other checks use, so a proxy
+	// or middlebox that interferes with HTTP/2 shows up here
+	proxyURL, err := protocolProxy(&http.Request{URL: parsedURL})
+	if err != nil {
+		logger.Printf("⚠️ Protocol check failed: invalid proxy settings: %v", err)
+		return
+	}
+	if proxyURL != nil {
+		result.Proxy = proxyURL.Redacted()
+		logger.Printf("🔀 %s is reached through proxy %s", serviceName, proxyURL.Host)
+	}
+
+	result.ALPN, err = negotiateALPN(parsedURL, proxyURL)
+	if err != nil {
+		result.ALPNError = err.Error()
+		logger.Printf("⚠️ ALPN negotiation with %s failed: %v", serviceName, err)
+	} else if result.ALPN == "" {
+		logger.Printf("⚠️ %s did not negotiate a protocol via ALPN, HTTP/2 is not offered", serviceName)
+	} else {
+		logger.Printf("✅ %s negotiated %q via ALPN", serviceName, result.ALPN)
+	}
+
+	http1Transport := &http.Transport{
+		Proxy:           protocolProxy,
+		DialContext:     dialContext,
+		TLSClientConfig: &tls.Config{NextProtos: []string{"http/1.1"}},
+		// A non-nil empty map disables the automatic HTTP/2 upgrade of the transport
+		TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},
+	}
+	result.HTTP1 = measureProtocol("HTTP/1.1", http1Transport, rawURL)
+
+	// Behind a proxy the transport tunnels HTTP/2 through CONNECT
+	http2Transport := &http.Transport{
+		Proxy:             protocolProxy,
+		DialContext:       dialContext,
+		ForceAttemptHTTP2: true,
+		TLSClientConfig:   &tls.Config{NextProtos: []string{"h2"}},
+	}
+	result.HTTP2 = measureProtocol("HTTP/2", http2Transport, rawURL)
+	if result.HTTP2.Error == "" && result.HTTP2.Proto != "HTTP/2.0" {
+		result.HTTP2.Error = fmt.Sprintf("HTTP/2 was not negotiated, the response came over %s", result.HTTP2.Proto)
+	}
+
+	for _, attempt := range []protocolAttempt{result.HTTP1, result.HTTP2} {
+		if attempt.Error != "" {
+			logger.Printf("❌ %s forced to %s failed: %s", serviceName, attempt.Protocol, attempt.Error)
+			continue
+		}
+		logger.Printf("✅ %s forced to %s: status %d (%s), cold %s, warm avg %s", serviceName, attempt.Protocol,
+			attempt.StatusCode, attempt.Proto, roundDuration(attempt.Cold), roundDuration(attempt.Warm))
+	}
+
+	switch {
+	case result.HTTP1.Error == "" && result.HTTP2.Error != "":
+		logger.Printf("⚠️ %s works over HTTP/1.1 but not HTTP/2, a middlebox may be interfering with HTTP/2", serviceName)
+	case result.HTTP1.Error == "" && result.HTTP2.Error == "" && result.HTTP1.StatusCode != result.HTTP2.StatusCode:
+		logger.Printf("⚠️ %s answers differently over HTTP/1.1 (%d) and HTTP/2 (%d)", serviceName, result.HTTP1.StatusCode, result.HTTP2.StatusCode)
+	}
+}
+
+// negotiateALPN opens a TLS connection offering h2 and http/1.1 and returns the negotiated protocol.
+// With a proxy the connection goes through a CONNECT tunnel.
+func negotiateALPN(parsedURL *url.URL, proxyURL *url.URL) (string, error) {
+	port := parsedURL.Port()
+	if port == "" {
+		port = "443"
+	}
+	address := net.JoinHostPort(parsedURL.Hostname(), port)
+	config := &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var conn *tls.Conn
+	if proxyURL == nil {
+		var err error
+		if conn, err = dialTLS(ctx, "tcp", address, config); err != nil {
+			return "", err
+		}
+	} else {
+		tunnel, err := dialProxyTunnel(ctx, proxyURL, address)
+		if err != nil {
+			return "", err
+		}
+		if conn, err = tlsHandshake(ctx, tunnel, address, config); err != nil {
+			return "", err
+		}
+	}
+	defer conn.Close()
+	return conn.ConnectionState().NegotiatedProtocol, nil
+}
+
+// dialProxyTunnel connects to the proxy and asks it to open a tunnel to the address with CONNECT
+func dialProxyTunnel(ctx context.Context, proxyURL *url.URL, address string) (net.Conn, error) {
+	proxyAddress := proxyURL.Host
+	if proxyURL.Port() == "" {
+		port := "80"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyAddress = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+	conn, err := dialContext(ctx, "tcp", proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		if conn, err = tlsHandshake(ctx, conn, proxyAddress, &tls.Config{}); err != nil {
+			return nil, err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	req := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: address}, Host: address, Header: http.Header{}}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s did not answer CONNECT: %v", proxyURL.Host, err)
+	}
+	// The body of a successful CONNECT is the tunnel, it must not be read
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s refused CONNECT to %s: %s", proxyURL.Host, address, resp.Status)
+	}
+	return conn, nil
+}
+
+// measureProtocol sends one cold and several warm GET requests through the transport
+func measureProtocol(protocol string, transport http.RoundTripper, rawURL string) protocolAttempt {
+	attempt := protocolAttempt{Protocol: protocol}
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+	defer client.CloseIdleConnections()
+
+	var warmTotal time.Duration
+	for i := 0; i <= protocolSamples; i++ {
+		start := time.Now()
+		resp, err := client.Get(rawURL)
+		if err != nil {
+			attempt.Error = err.Error()
+			return attempt
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		elapsed := time.Since(start)
+
+		attempt.StatusCode = resp.StatusCode
+		attempt.Proto = resp.Proto
+		if i == 0 {
+			attempt.Cold = elapsed
+		} else {
+			warmTotal += elapsed
+		}
+	}
+	attempt.Warm = warmTotal / protocolSamples
+	attempt.ColdMS = milliseconds(attempt.Cold)
+	attempt.WarmMS = milliseconds(attempt.Warm)
+	return attempt
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// connectProxy is a forward proxy that only tunnels CONNECT requests and counts them
+func connectProxy(tunnels *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		upstream, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		atomic.AddInt32(tunnels, 1)
+		w.WriteHeader(http.StatusOK)
+		conn, buffered, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		go func() {
+			io.Copy(upstream, buffered)
+			upstream.Close()
+		}()
+		io.Copy(conn, upstream)
+		conn.Close()
+	}))
+}
+
+// trustServer makes the certificate of the test servers trusted by the transports of the tool. The
+// system roots are loaded once, before the first TLS handshake, and all test servers share a certificate.
+func trustServer(t *testing.T, server *httptest.Server) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "cert.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(file, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SSL_CERT_FILE", file)
+}
+
+func TestProtocolNegotiationThroughProxy(t *testing.T) {
+	var tunnels int32
+	proxy := connectProxy(&tunnels)
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	defer func(saved func(*http.Request) (*url.URL, error)) { protocolProxy = saved }(protocolProxy)
+	protocolProxy = http.ProxyURL(proxyURL)
+	defer func(saved []protocolResult) { report.Protocols = saved }(report.Protocols)
+
+	tests := []struct {
+		name      string
+		http2     bool
+		alpn      string
+		http2Fail bool
+	}{
+		{name: "HTTP/2 gateway", http2: true, alpn: "h2"},
+		{name: "HTTP/1.1 only", alpn: "http/1.1", http2Fail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+			}))
+			gateway.EnableHTTP2 = tt.http2
+			gateway.StartTLS()
+			defer gateway.Close()
+			trustServer(t, gateway)
+
+			atomic.StoreInt32(&tunnels, 0)
+			report.Protocols = nil
+			checkProtocolNegotiation(gateway.URL, "test")
+			if len(report.Protocols) != 1 {
+				t.Fatalf("report has %d protocol results, want 1", len(report.Protocols))
+			}
+			result := report.Protocols[0]
+
+			if result.ALPN != tt.alpn || result.ALPNError != "" {
+				t.Errorf("ALPN = %q (%s), want %s", result.ALPN, result.ALPNError, tt.alpn)
+			}
+			if result.HTTP1.Error != "" || result.HTTP1.Proto != "HTTP/1.1" || result.HTTP1.StatusCode != http.StatusUnauthorized {
+				t.Errorf("HTTP/1.1 attempt = %+v", result.HTTP1)
+			}
+			if tt.http2Fail && result.HTTP2.Error == "" {
+				t.Errorf("HTTP/2 attempt = %+v, want an error", result.HTTP2)
+			}
+			if !tt.http2Fail && (result.HTTP2.Error != "" || result.HTTP2.Proto != "HTTP/2.0" || result.HTTP2.StatusCode != http.StatusUnauthorized) {
+				t.Errorf("HTTP/2 attempt = %+v", result.HTTP2)
+			}
+			if result.Proxy == "" {
+				t.Error("the proxy is not recorded in the result")
+			}
+			// One tunnel for ALPN and one per transport, the warm requests reuse the connection
+			if got := atomic.LoadInt32(&tunnels); got != 3 {
+				t.Errorf("proxy opened %d tunnels, want 3", got)
+			}
+		})
+	}
+}
+
+func TestDialProxyTunnelRefused(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") == "" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+		}
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	if _, err := negotiateALPN(mustParseURL(t, "https://gateway.example.com"), proxyURL); err == nil {
+		t.Error("negotiateALPN() succeeded through a proxy that requires authentication")
+	}
+	proxyURL.User = url.UserPassword("user", "secret")
+	conn, err := dialProxyTunnel(context.Background(), proxyURL, "gateway.example.com:443")
+	if err != nil {
+		t.Fatalf("dialProxyTunnel() with credentials failed: %v", err)
+	}
+	conn.Close()
+}
+
+// mustParseURL parses a URL of a test
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsedURL
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// runReport collects the results of a run so they can be summarised on the console and saved to a file
+type runReport struct {
+	mu sync.Mutex
+
+	GeneratedAt   time.Time          `json:"generated_at"`
+	IonBaseURL    string             `json:"ion_base_url,omitempty"`
+	TenantID      string             `json:"tenant_id,omitempty"`
+	Resolve       map[string]string  `json:"resolve_overrides,omitempty"`
+	Probes        []probeResult      `json:"probes,omitempty"`
+	Protocols     []protocolResult   `json:"protocols,omitempty"`
+	Timings       []requestTiming    `json:"timings,omitempty"`
+	Allowlist     []allowlistEntry   `json:"allowlist,omitempty"`
+	Bulk          *bulkSummary       `json:"m3_bulk,omitempty"`
+	Export        *exportSummary     `json:"m3_export,omitempty"`
+	M3Checks      []m3CheckResult    `json:"m3_checks,omitempty"`
+	M3Environment *m3Environment     `json:"m3_environment,omitempty"`
+	Bench         *benchSummary      `json:"m3_bench,omitempty"`
+	Identity      *identityResult    `json:"identity,omitempty"`
+	DataLake      *dataLakeSummary   `json:"datalake,omitempty"`
+	BODValidation []*bodValidation   `json:"bod_validation,omitempty"`
+	Suites        []suiteResult      `json:"suites,omitempty"`
+	ERP           *erpCheckResult    `json:"erp,omitempty"`
+	IDM           *idmSummary        `json:"idm,omitempty"`
+	IonMessage    *ionMessageSummary `json:"ion_message,omitempty"`
+}
+
+// report is the report of the current run
+var report = &runReport{GeneratedAt: time.Now()}
+
+// reportFile is the path the report is written to, set with --report
+var reportFile string
+
+// addTiming records the timing breakdown of an HTTP request
+func (r *runReport) addTiming(t requestTiming) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Timings = append(r.Timings, t)
+}
+
+// addProbe records the result of an endpoint probe
+func (r *runReport) addProbe(p probeResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Probes = append(r.Probes, p)
+}
+
+// addProtocol records the result of a protocol negotiation check
+func (r *runReport) addProtocol(p protocolResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Protocols = append(r.Protocols, p)
+}
+
+// addM3Check records the result of an M3 health check
+func (r *runReport) addM3Check(c m3CheckResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.M3Checks = append(r.M3Checks, c)
+}
+
+// printSummary prints the M3 environment, the M3 check results and the collected request timings as tables
+func (r *runReport) printSummary() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := logger.Writer()
+
+	if r.M3Environment != nil {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "M3 environment:")
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		for _, row := range r.M3Environment.rows() {
+			fmt.Fprintf(w, "%s\t%s\n", row[0], row[1])
+		}
+		w.Flush()
+	}
+
+	if len(r.M3Checks) > 0 {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "M3 checks:")
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tTRANSACTION\tRESULT\tRECORDS\tLATENCY")
+		for _, c := range r.M3Checks {
+			status := "passed"
+			if !c.Passed {
+				status = "FAILED"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.0f ms\n", c.Name, c.Transaction, status, c.Records, c.LatencyMS)
+		}
+		w.Flush()
+	}
+
+	if len(r.Timings) == 0 {
+		return
+	}
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "Request timing breakdown:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REQUEST\tDNS\tCONNECT\tTLS\tTTFB\tTRANSFER\tTOTAL")
+	for _, t := range r.Timings {
+		if t.Error != "" {
+			t.Label += " (failed)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.Label, roundDuration(t.DNS), roundDuration(t.Connect),
+			roundDuration(t.TLSHandshake), roundDuration(t.TimeToFirstByte), roundDuration(t.Transfer), roundDuration(t.Total))
+	}
+	w.Flush()
+}
+
+// save writes the report as JSON to the given path
+func (r *runReport) save(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// reportFinished is set once the report was printed and saved
+var reportFinished bool
+
+// finishReport prints the report summary and saves it if --report was given. Only the first call
+// does so, failing commands call it again before they exit.
+func finishReport() {
+	if reportFinished {
+		return
+	}
+	reportFinished = true
+	report.printSummary()
+	if reportFile == "" {
+		return
+	}
+	if err := report.save(reportFile); err != nil {
+		logger.Printf("⚠️ Failed to write report to %s: %v", reportFile, err)
+		return
+	}
+	logger.Printf("📝 Report written to %s", reportFile)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// resolveOverrides maps host:port to the IP address given with --resolve, like curl's option of the same name
+var resolveOverrides = map[string]string{}
+
+// dialer is used for every TCP connection so --resolve overrides are honoured everywhere
+var dialer = &net.Dialer{Timeout: 5 * time.Second, KeepAlive: 30 * time.Second}
+
+// addResolveOverride parses a host:port:ip entry and registers it
+func addResolveOverride(entry string) error {
+	parts := strings.SplitN(entry, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return fmt.Errorf("invalid --resolve entry %q, expected host:port:ip", entry)
+	}
+	ip := strings.TrimSuffix(strings.TrimPrefix(parts[2], "["), "]")
+	if net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid IP address %q in --resolve entry %q", ip, entry)
+	}
+
+	resolveOverrides[net.JoinHostPort(strings.ToLower(parts[0]), parts[1])] = ip
+	logger.Printf("🔀 Connections to %s:%s will use %s", parts[0], parts[1], ip)
+	return nil
+}
+
+// installResolveOverrides makes the default HTTP transport dial through dialContext
+func installResolveOverrides() {
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport.DialContext = dialContext
+	}
+}
+
+// resolvedAddress returns the address to dial for host:port, applying any --resolve override
+func resolvedAddress(address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip, ok := resolveOverrides[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		return net.JoinHostPort(ip, port)
+	}
+	return address
+}
+
+// resolveOverrideForHost returns the override IP for a host on any port, used by the DNS check
+func resolveOverrideForHost(host string) (string, bool) {
+	for address, ip := range resolveOverrides {
+		overrideHost, _, err := net.SplitHostPort(address)
+		if err == nil && strings.EqualFold(overrideHost, host) {
+			return ip, true
+		}
+	}
+	return "", false
+}
+
+// dialContext dials the address, replacing it with the --resolve override if one matches.
+// TLS connections made on top keep the original hostname for SNI and certificate checks.
+func dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	target := resolvedAddress(address)
+	if target != address {
+		debugPrint("🔀 Dialing %s instead of %s (--resolve)", target, address)
+	}
+	return dialer.DialContext(ctx, network, target)
+}
+
+// dialTLS opens a TLS connection through dialContext. The ServerName of the config defaults to
+// the host of the original address so SNI is correct when the address is overridden.
+func dialTLS(ctx context.Context, network, address string, config *tls.Config) (*tls.Conn, error) {
+	conn, err := dialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return tlsHandshake(ctx, conn, address, config)
+}
+
+// tlsHandshake starts TLS on a connection to the address, closing it if the handshake fails
+func tlsHandshake(ctx context.Context, conn net.Conn, address string, config *tls.Config) (*tls.Conn, error) {
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(address)
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestAddResolveOverride(t *testing.T) {
+	tests := []struct {
+		entry   string
+		address string
+		ip      string
+		wantErr bool
+	}{
+		{entry: "mingle-ionapi.inforcloudsuite.com:443:10.0.0.5", address: "mingle-ionapi.inforcloudsuite.com:443", ip: "10.0.0.5"},
+		{entry: "Gateway.Example.com:8443:10.0.0.6", address: "gateway.example.com:8443", ip: "10.0.0.6"},
+		{entry: "gateway.example.com:443:[2001:db8::1]", address: "gateway.example.com:443", ip: "2001:db8::1"},
+		{entry: "gateway.example.com:443:2001:db8::2", address: "gateway.example.com:443", ip: "2001:db8::2"},
+		{entry: "gateway.example.com:443", wantErr: true},
+		{entry: "gateway.example.com::10.0.0.5", wantErr: true},
+		{entry: ":443:10.0.0.5", wantErr: true},
+		{entry: "gateway.example.com:443:not-an-ip", wantErr: true},
+		{entry: "gateway.example.com:443:", wantErr: true},
+	}
+	defer func(saved map[string]string) { resolveOverrides = saved }(resolveOverrides)
+	for _, tt := range tests {
+		resolveOverrides = map[string]string{}
+		err := addResolveOverride(tt.entry)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("addResolveOverride(%q) succeeded, want an error", tt.entry)
+			}
+			if len(resolveOverrides) != 0 {
+				t.Errorf("addResolveOverride(%q) registered %v for an invalid entry", tt.entry, resolveOverrides)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("addResolveOverride(%q) failed: %v", tt.entry, err)
+			continue
+		}
+		if got := resolveOverrides[tt.address]; got != tt.ip {
+			t.Errorf("addResolveOverride(%q) registered %v, want %s for %s", tt.entry, resolveOverrides, tt.ip, tt.address)
+		}
+	}
+}
+
+func TestResolvedAddress(t *testing.T) {
+	defer func(saved map[string]string) { resolveOverrides = saved }(resolveOverrides)
+	resolveOverrides = map[string]string{"gateway.example.com:443": "10.0.0.5"}
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"gateway.example.com:443", "10.0.0.5:443"},
+		{"GATEWAY.example.com:443", "10.0.0.5:443"},
+		{"gateway.example.com:8443", "gateway.example.com:8443"},
+		{"other.example.com:443", "other.example.com:443"},
+		{"no-port", "no-port"},
+	}
+	for _, tt := range tests {
+		if got := resolvedAddress(tt.address); got != tt.want {
+			t.Errorf("resolvedAddress(%q) = %q, want %q", tt.address, got, tt.want)
+		}
+	}
+}
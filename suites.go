@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiCatalogPath is the default gateway path of the API catalog listing the suites of the tenant.
+// Gateways that publish the catalog elsewhere can set it with --catalog-path.
+const apiCatalogPath = "/ionapi/catalog/v1/suites"
+
+// Availability of an API suite for the service account
+const (
+	suiteAvailable     = "available"
+	suiteNotAuthorized = "not authorized"
+	suiteNotAvailable  = "not available"
+	suiteAPINotFound   = "reachable (404 from API)"
+	suiteTokenRejected = "token rejected"
+	suiteServerError   = "server error"
+	suiteUnreachable   = "unreachable"
+)
+
+// apiSuite is an API suite of the gateway with a cheap read-only request to probe it
+type apiSuite struct {
+	Name      string `json:"name"`
+	BasePath  string `json:"base_path"`
+	ProbePath string `json:"probe_path,omitempty"`
+	Source    string `json:"source,omitempty"`
+	// baseProbe is set when no probe request is known and the base path is probed instead
+	baseProbe bool
+}
+
+// suiteResult is the outcome of probing an API suite, added to the report
+type suiteResult struct {
+	apiSuite
+	Status     string  `json:"status"`
+	StatusCode int     `json:"status_code,omitempty"`
+	LatencyMS  float64 `json:"latency_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// knownSuites are the API suites probed when the catalog is not available, with the paths the
+// other commands use
+var knownSuites = []apiSuite{
+	{Name: "M3", BasePath: "/M3", ProbePath: m3Product{}.probePath()},
+	{Name: "CloudSuite Industrial", BasePath: "/CSI", ProbePath: csiProduct{}.probePath()},
+	{Name: "LN", BasePath: "/LN", ProbePath: lnProduct{service: "tdapi.slsSalesOrder"}.probePath()},
+	{Name: "User Management (IFS)", BasePath: "/IFS", ProbePath: identityUserPath},
+	{Name: "OS Portal", BasePath: "/OSPORTAL", ProbePath: identityApplicationsPath},
+	{Name: "Document Management (IDM)", BasePath: "/IDM", ProbePath: idmEntitiesPath},
+	{Name: "Data Fabric", BasePath: "/DATAFABRIC", ProbePath: dataCatalogPath},
+	{Name: "ION Services", BasePath: "/IONSERVICES", ProbePath: ionTrackingPath},
+}
+
+// runSuites lists the API suites of the tenant and probes each of them:
+// suites <ionapi-file-path> [--catalog-path <path>] [--suites <file.json>] [--format table|json|csv]
+func runSuites(args []string) error {
+	cli := parseArgs(args, "--catalog-path", "--suites", "--format")
+	if len(cli.positional) < 1 {
+		return fmt.Errorf("usage: suites <ionapi-file-path> [--catalog-path <path>] [--suites <file.json>] [--format table|json|csv]")
+	}
+	applyGlobalFlags(cli)
+	format := cli.value("--format", "table")
+	reserveStdout(format)
+
+	ionAPI, token, err := authenticate(cli.positional[0])
+	if err != nil {
+		return err
+	}
+	client := newIonClient(ionAPI, token)
+
+	suites, err := catalogSuites(client, cli.value("--catalog-path", apiCatalogPath))
+	if err != nil {
+		logger.Printf("⚠️ API catalog not available (%v), probing the known suites", err)
+		suites = knownSuites
+	} else {
+		logger.Printf("✅ %d suite(s) listed in the API catalog", len(suites))
+	}
+	if file := cli.value("--suites", ""); file != "" {
+		extra, err := loadSuites(file)
+		if err != nil {
+			return err
+		}
+		suites = append(suites, extra...)
+	}
+
+	available := 0
+	records := make([]map[string]string, len(suites))
+	for i, suite := range suites {
+		result := probeSuite(client, suite)
+		report.Suites = append(report.Suites, result)
+		if result.Status == suiteAvailable || result.Status == suiteAPINotFound {
+			available++
+		}
+		records[i] = map[string]string{
+			"SUITE":      result.Name,
+			"BASE_PATH":  result.BasePath,
+			"SOURCE":     result.Source,
+			"STATUS":     result.Status,
+			"HTTP":       "",
+			"LATENCY_MS": strconv.FormatFloat(result.LatencyMS, 'f', 0, 64),
+		}
+		if result.StatusCode > 0 {
+			records[i]["HTTP"] = strconv.Itoa(result.StatusCode)
+		}
+	}
+
+	logger.Printf("✅ %d of %d suite(s) reachable for this service account", available, len(suites))
+	columns := []column{{Name: "SUITE"}, {Name: "BASE_PATH"}, {Name: "SOURCE"}, {Name: "STATUS"}, {Name: "HTTP", Type: "N"}, {Name: "LATENCY_MS", Type: "N"}}
+	if err := printRecords(os.Stdout, format, columns, records); err != nil {
+		return err
+	}
+	finishReport()
+	return nil
+}
+
+// catalogSuites reads the suites of the tenant from the API catalog. Suites known to the tool are
+// probed with the same request as without the catalog, others with a GET of their base path, where
+// a 404 may come from the API itself.
+func catalogSuites(client *ionClient, path string) ([]apiSuite, error) {
+	var catalog interface{}
+	if err := client.getJSON(path, "API catalog", &catalog); err != nil {
+		return nil, err
+	}
+	list, _ := catalog.([]interface{})
+	if object, ok := catalog.(map[string]interface{}); ok {
+		for _, key := range []string{"suites", "apis", "items", "response", "data"} {
+			if inner, ok := object[key].([]interface{}); ok {
+				list = inner
+				break
+			}
+		}
+	}
+
+	var suites []apiSuite
+	for _, item := range list {
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		suite := apiSuite{
+			Name:     jsonString(object, "name", "displayName", "suiteName"),
+			BasePath: jsonString(object, "basePath", "contextRoot", "context", "path"),
+			Source:   "catalog",
+		}
+		if suite.BasePath == "" {
+			continue
+		}
+		suite.BasePath = "/" + strings.Trim(suite.BasePath, "/")
+		suite.ProbePath, suite.baseProbe = suite.BasePath+"/", true
+		for _, known := range knownSuites {
+			if strings.EqualFold(known.BasePath, suite.BasePath) {
+				suite.ProbePath, suite.baseProbe = known.ProbePath, false
+			}
+		}
+		if suite.Name == "" {
+			suite.Name = strings.TrimPrefix(suite.BasePath, "/")
+		}
+		suites = append(suites, suite)
+	}
+	if len(suites) == 0 {
+		return nil, fmt.Errorf("no suites in the response of %s", path)
+	}
+	return suites, nil
+}
+
+// loadSuites reads additional suites to probe from a JSON list of {"name", "base_path", "probe_path"}
+func loadSuites(file string) ([]apiSuite, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var suites []apiSuite
+	if err := json.Unmarshal(content, &suites); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+	for i := range suites {
+		// Without a known request the 404 of a suite that is not activated cannot be told apart
+		if suites[i].ProbePath == "" {
+			return nil, fmt.Errorf("suite %q in %s has no probe_path, set it to a read-only request of the API", suites[i].Name, file)
+		}
+		suites[i].Source = file
+	}
+	return suites, nil
+}
+
+// probeSuite sends the probe request of a suite and classifies the answer. The gateway answers
+// 404 for suites that are not activated for the tenant and 403 for suites the user has no role for;
+// other client errors come from the API itself and prove it is reachable.
+func probeSuite(client *ionClient, suite apiSuite) suiteResult {
+	if suite.Source == "" {
+		suite.Source = "built-in"
+	}
+	result := suiteResult{apiSuite: suite}
+	req, err := client.newRequest("GET", suite.ProbePath, nil)
+	if err != nil {
+		result.Status, result.Error = suiteUnreachable, err.Error()
+		return result
+	}
+	start := time.Now()
+	resp, _, err := client.do(req, suite.Name+" probe")
+	result.LatencyMS = milliseconds(time.Since(start))
+	if err != nil {
+		result.Status, result.Error = suiteUnreachable, err.Error()
+		logger.Printf("❌ %s is unreachable: %v", suite.Name, err)
+		return result
+	}
+
+	result.StatusCode = resp.StatusCode
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		result.Status = suiteTokenRejected
+	case resp.StatusCode == http.StatusForbidden:
+		result.Status = suiteNotAuthorized
+	case resp.StatusCode == http.StatusNotFound && suite.baseProbe:
+		result.Status = suiteAPINotFound
+	case resp.StatusCode == http.StatusNotFound:
+		result.Status = suiteNotAvailable
+	case resp.StatusCode >= 500:
+		result.Status = suiteServerError
+	default:
+		result.Status = suiteAvailable
+	}
+	debugPrint("%s probe %s: %s", suite.Name, suite.ProbePath, resp.Status)
+	return result
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatalogSuites(t *testing.T) {
+	catalog := `{"suites": [
+		{"name": "M3 Business Engine", "basePath": "m3/"},
+		{"displayName": "Birst", "contextRoot": "/BIRST"},
+		{"contextRoot": "/CUSTOM/"},
+		{"name": "No path"}
+	]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ACME_TST/empty" {
+			w.Write([]byte(`{"suites": []}`))
+			return
+		}
+		w.Write([]byte(catalog))
+	}))
+	defer server.Close()
+	client := newIonClient(&IonAPI{IonBaseURL: server.URL, TenantID: "ACME_TST"}, "test-token")
+
+	suites, err := catalogSuites(client, apiCatalogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []apiSuite{
+		{Name: "M3 Business Engine", BasePath: "/m3", ProbePath: m3Product{}.probePath(), Source: "catalog"},
+		{Name: "Birst", BasePath: "/BIRST", ProbePath: "/BIRST/", Source: "catalog", baseProbe: true},
+		{Name: "CUSTOM", BasePath: "/CUSTOM", ProbePath: "/CUSTOM/", Source: "catalog", baseProbe: true},
+	}
+	if !reflect.DeepEqual(suites, want) {
+		t.Errorf("catalogSuites() = %+v, want %+v", suites, want)
+	}
+
+	if _, err := catalogSuites(client, "/empty"); err == nil || !strings.Contains(err.Error(), "no suites") {
+		t.Errorf("catalogSuites() of an empty catalog error = %v", err)
+	}
+}
+
+func TestProbeSuite(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/ACME_TST") {
+		case "/ok/":
+			w.WriteHeader(http.StatusOK)
+		case "/bad-request/":
+			w.WriteHeader(http.StatusBadRequest)
+		case "/unauthorized/":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/forbidden/":
+			w.WriteHeader(http.StatusForbidden)
+		case "/broken/":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := newIonClient(&IonAPI{IonBaseURL: server.URL, TenantID: "ACME_TST"}, "test-token")
+
+	tests := []struct {
+		suite  apiSuite
+		status string
+	}{
+		{apiSuite{Name: "ok", ProbePath: "/ok/"}, suiteAvailable},
+		{apiSuite{Name: "bad request", ProbePath: "/bad-request/"}, suiteAvailable},
+		{apiSuite{Name: "unauthorized", ProbePath: "/unauthorized/"}, suiteTokenRejected},
+		{apiSuite{Name: "forbidden", ProbePath: "/forbidden/"}, suiteNotAuthorized},
+		{apiSuite{Name: "not activated", ProbePath: "/missing/"}, suiteNotAvailable},
+		{apiSuite{Name: "base path", ProbePath: "/missing/", baseProbe: true}, suiteAPINotFound},
+		{apiSuite{Name: "broken", ProbePath: "/broken/"}, suiteServerError},
+		{apiSuite{Name: "other host", ProbePath: "https://example.com/"}, suiteUnreachable},
+	}
+	for _, tt := range tests {
+		result := probeSuite(client, tt.suite)
+		if result.Status != tt.status {
+			t.Errorf("probeSuite(%s) = %q (%d), want %q", tt.suite.Name, result.Status, result.StatusCode, tt.status)
+		}
+		if result.Source != "built-in" {
+			t.Errorf("probeSuite(%s) source = %q, want built-in", tt.suite.Name, result.Source)
+		}
+	}
+}
+
+func TestLoadSuites(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "suites.json")
+	if err := ioutil.WriteFile(valid, []byte(`[{"name": "Birst", "base_path": "/BIRST", "probe_path": "/BIRST/api/v1/spaces"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	suites, err := loadSuites(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []apiSuite{{Name: "Birst", BasePath: "/BIRST", ProbePath: "/BIRST/api/v1/spaces", Source: valid}}; !reflect.DeepEqual(suites, want) {
+		t.Errorf("loadSuites() = %+v, want %+v", suites, want)
+	}
+
+	noProbe := filepath.Join(dir, "no-probe.json")
+	if err := ioutil.WriteFile(noProbe, []byte(`[{"name": "Birst", "base_path": "/BIRST"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSuites(noProbe); err == nil || !strings.Contains(err.Error(), "has no probe_path") {
+		t.Errorf("loadSuites() without probe_path error = %v", err)
+	}
+}
+
+func TestRunSuitesWithoutCatalog(t *testing.T) {
+	gateway := newTestGateway(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch strings.TrimPrefix(r.URL.Path, "/ACME_TST") {
+		case identityUserPath, idmEntitiesPath:
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer func(saved []suiteResult) { report.Suites = saved }(report.Suites)
+	defer logger.SetOutput(logger.Writer())
+	report.Suites = nil
+
+	if err := runSuites([]string{gateway.ionAPIFile, "--format", "json"}); err != nil {
+		t.Fatalf("runSuites() failed: %v", err)
+	}
+	if len(report.Suites) != len(knownSuites) {
+		t.Fatalf("%d suites probed, want the %d known suites", len(report.Suites), len(knownSuites))
+	}
+	for _, result := range report.Suites {
+		want := suiteNotAvailable
+		if result.BasePath == "/IFS" || result.BasePath == "/IDM" {
+			want = suiteAvailable
+		}
+		if result.Status != want {
+			t.Errorf("suite %s is %q, want %q", result.Name, result.Status, want)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTiming holds the per-phase timings of a single HTTP request
+type requestTiming struct {
+	Label           string
+	Method          string
+	URL             string
+	DNS             time.Duration
+	Connect         time.Duration
+	TLSHandshake    time.Duration
+	TimeToFirstByte time.Duration
+	Transfer        time.Duration
+	Total           time.Duration
+	ReusedConn      bool
+	RemoteAddr      string
+	Error           string
+
+	start, dnsStart, connectStart, tlsStart, wroteRequest, firstByte time.Time
+
+	// mu guards the fields set by the trace, whose hooks run on the dialing goroutines: happy
+	// eyeballs dials in parallel and an abandoned dial may still report after finish
+	mu *sync.Mutex
+}
+
+// withTiming attaches an httptrace.ClientTrace to the request that records the DNS, connect,
+// TLS handshake and time-to-first-byte phases. Call finish once the response body has been read.
+func withTiming(req *http.Request, label string) (*http.Request, *requestTiming) {
+	t := &requestTiming{Label: label, Method: req.Method, URL: req.URL.String(), mu: &sync.Mutex{}}
+	locked := func(record func()) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		record()
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { locked(func() { t.dnsStart = time.Now() }) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			locked(func() {
+				if !t.dnsStart.IsZero() {
+					t.DNS = time.Since(t.dnsStart)
+				}
+			})
+		},
+		ConnectStart: func(network, addr string) {
+			locked(func() {
+				// Only the first dial is timed, happy eyeballs may start a second one
+				if t.connectStart.IsZero() {
+					t.connectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(network, addr string, err error) {
+			locked(func() {
+				if err == nil && t.Connect == 0 && !t.connectStart.IsZero() {
+					t.Connect = time.Since(t.connectStart)
+				}
+			})
+		},
+		TLSHandshakeStart: func() { locked(func() { t.tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			locked(func() {
+				if !t.tlsStart.IsZero() {
+					t.TLSHandshake = time.Since(t.tlsStart)
+				}
+			})
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			locked(func() {
+				t.ReusedConn = info.Reused
+				if info.Conn != nil {
+					t.RemoteAddr = info.Conn.RemoteAddr().String()
+				}
+			})
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { locked(func() { t.wroteRequest = time.Now() }) },
+		GotFirstResponseByte: func() { locked(func() { t.firstByte = time.Now() }) },
+	}
+
+	t.start = time.Now()
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), t
+}
+
+// finish closes the timing of the request, logs it and records it in the run report
+func (t *requestTiming) finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := time.Now()
+	t.Total = end.Sub(t.start)
+	if !t.firstByte.IsZero() {
+		// Over HTTP/2 the request may be reported as written only after the response started
+		if !t.wroteRequest.IsZero() && t.wroteRequest.Before(t.firstByte) {
+			t.TimeToFirstByte = t.firstByte.Sub(t.wroteRequest)
+		} else {
+			t.TimeToFirstByte = t.firstByte.Sub(t.start)
+		}
+		t.Transfer = end.Sub(t.firstByte)
+	}
+
+	t.log()
+	report.addTiming(*t)
+}
+
+// fail closes the timing of a request that failed with a transport error and records the error
+func (t *requestTiming) fail(err error) {
+	t.mu.Lock()
+	t.Error = err.Error()
+	t.mu.Unlock()
+	t.finish()
+}
+
+// MarshalJSON writes the phase durations in milliseconds so reports stay readable
+func (t requestTiming) MarshalJSON() ([]byte, error) {
+	ms := milliseconds
+	return json.Marshal(struct {
+		Label           string  `json:"label"`
+		Method          string  `json:"method"`
+		URL             string  `json:"url"`
+		DNS             float64 `json:"dns_ms"`
+		Connect         float64 `json:"connect_ms"`
+		TLSHandshake    float64 `json:"tls_handshake_ms"`
+		TimeToFirstByte float64 `json:"time_to_first_byte_ms"`
+		Transfer        float64 `json:"transfer_ms"`
+		Total           float64 `json:"total_ms"`
+		ReusedConn      bool    `json:"reused_connection"`
+		RemoteAddr      string  `json:"remote_addr,omitempty"`
+		Error           string  `json:"error,omitempty"`
+	}{t.Label, t.Method, t.URL, ms(t.DNS), ms(t.Connect), ms(t.TLSHandshake), ms(t.TimeToFirstByte),
+		ms(t.Transfer), ms(t.Total), t.ReusedConn, t.RemoteAddr, t.Error})
+}
+
+// log prints the timing breakdown of the request to the console
+func (t *requestTiming) log() {
+	if t.Error != "" {
+		logger.Printf("⏱️  %s: failed after %s (DNS %s, connect %s, TLS %s): %s", t.Label, roundDuration(t.Total),
+			roundDuration(t.DNS), roundDuration(t.Connect), roundDuration(t.TLSHandshake), t.Error)
+		return
+	}
+	logger.Printf("⏱️  %s: total %s (DNS %s, connect %s, TLS %s, TTFB %s, transfer %s)",
+		t.Label, roundDuration(t.Total), roundDuration(t.DNS), roundDuration(t.Connect),
+		roundDuration(t.TLSHandshake), roundDuration(t.TimeToFirstByte), roundDuration(t.Transfer))
+	if t.ReusedConn {
+		debugPrint("   %s reused an existing connection to %s", t.Label, t.RemoteAddr)
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds for reports
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// roundDuration rounds a duration to a readable precision for console output
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(100 * time.Microsecond)
+}
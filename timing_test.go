@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithTiming(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	defer func(saved []requestTiming) { report.Timings = saved }(report.Timings)
+	report.Timings = nil
+
+	for i, reused := range []bool{false, true} {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req, timing := withTiming(req, "Test request")
+		resp, err := server.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		timing.finish()
+
+		if len(report.Timings) != i+1 {
+			t.Fatalf("report has %d timings, want %d", len(report.Timings), i+1)
+		}
+		recorded := report.Timings[i]
+		if recorded.ReusedConn != reused || recorded.RemoteAddr != server.Listener.Addr().String() {
+			t.Errorf("request %d reused %v from %s", i+1, recorded.ReusedConn, recorded.RemoteAddr)
+		}
+		if !reused && recorded.Connect == 0 {
+			t.Error("the connect phase of a new connection is not timed")
+		}
+		if recorded.TimeToFirstByte < 5*time.Millisecond || recorded.Total < recorded.TimeToFirstByte {
+			t.Errorf("time to first byte %s, total %s", recorded.TimeToFirstByte, recorded.Total)
+		}
+	}
+}
+
+func TestTimingParallelDials(t *testing.T) {
+	defer func(saved []requestTiming) { report.Timings = saved }(report.Timings)
+
+	// Happy eyeballs reports the dials of both address families from their own goroutines,
+	// and an abandoned dial may report after the request was finished
+	req, _ := http.NewRequest("GET", "https://gateway.example.com", nil)
+	req, timing := withTiming(req, "Test request")
+	trace := httptrace.ContextClientTrace(req.Context())
+	var wg sync.WaitGroup
+	for _, addr := range []string{"[2001:db8::1]:443", "192.0.2.1:443"} {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			trace.ConnectStart("tcp", addr)
+			trace.ConnectDone("tcp", addr, nil)
+			trace.TLSHandshakeStart()
+		}(addr)
+	}
+	timing.fail(errors.New("dial tcp: i/o timeout"))
+	wg.Wait()
+
+	if timing.Error != "dial tcp: i/o timeout" {
+		t.Errorf("timing error = %q", timing.Error)
+	}
+}
@@ -33,21 +33,26 @@ func getAccessToken(api *IonAPI) (string, error) {
 	req.Header.Set("Authorization", "Basic "+auth)
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
+	req, timing := withTiming(req, "Token request")
+
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
+		timing.fail(err)
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get token, status: %s", resp.Status)
-	}
-
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
+		timing.fail(err)
 		return "", err
 	}
+	timing.finish()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get token, status: %s", resp.Status)
+	}
 
 	var result map[string]interface{}
 	err = json.Unmarshal(body, &result)
@@ -62,3 +67,30 @@ func getAccessToken(api *IonAPI) (string, error) {
 
 	return accessToken, nil
 }
+
+// authenticate loads the .ionapi file and obtains an access token, for commands that call ION APIs
+func authenticate(ionAPIFile string) (*IonAPI, string, error) {
+	ionAPI, err := loadIonAPI(ionAPIFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load ionapi file: %v", err)
+	}
+	token, err := authenticateIonAPI(ionAPI)
+	if err != nil {
+		return nil, "", err
+	}
+	return ionAPI, token, nil
+}
+
+// authenticateIonAPI obtains an access token for a loaded .ionapi file and records the tenant in the report
+func authenticateIonAPI(ionAPI *IonAPI) (string, error) {
+	report.IonBaseURL = ionAPI.IonBaseURL
+	report.TenantID = ionAPI.TenantID
+	report.Resolve = resolveOverrides
+
+	token, err := getAccessToken(ionAPI)
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %v", err)
+	}
+	debugPrint("Access Token: %s", token)
+	return token, nil
+}